@@ -0,0 +1,26 @@
+//go:build go1.20
+// +build go1.20
+
+package exec
+
+import "time"
+
+// setCancel makes the cancellation of the command's context go
+// through the graceful stop sequence instead of killing the process.
+// Wait stops waiting for the output of leftover descendants one
+// second after the grace period.
+func (c *Cmd) setCancel(done <-chan struct{}) {
+	if c.ctx == nil {
+		return
+	}
+
+	cmd := c.Cmd
+	cmd.Cancel = func() error {
+		go c.terminate(cmd.Process, done)
+		return nil
+	}
+
+	cmd.WaitDelay = c.grace() + time.Second
+}
+
+func (c *Cmd) watchContext(done <-chan struct{}) {}
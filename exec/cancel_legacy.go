@@ -0,0 +1,26 @@
+//go:build !go1.20
+// +build !go1.20
+
+package exec
+
+// setCancel is a no-op, os/exec kills the process when the context
+// is done.
+func (c *Cmd) setCancel(done <-chan struct{}) {}
+
+// watchContext kills the process group of the command once the
+// context is done so descendants do not outlive the command.
+func (c *Cmd) watchContext(done <-chan struct{}) {
+	if c.ctx == nil || (*c.ctx).Done() == nil || !c.group {
+		return
+	}
+
+	p := c.Cmd.Process
+	ctx := *c.ctx
+	go func() {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			_ = killProcess(p, true)
+		}
+	}()
+}
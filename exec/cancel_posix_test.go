@@ -0,0 +1,64 @@
+//go:build !windows
+// +build !windows
+
+package exec_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hyprxlabs/go/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextCancelStopsProcessGroup(t *testing.T) {
+	_, ok := exec.Which("sh")
+	if !ok {
+		t.Skip("sh not found")
+	}
+
+	file := filepath.Join(t.TempDir(), "pid")
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	o, err := exec.NewContext(ctx, "sh", "-c", `trap 'echo stopping; exit 143' TERM; sleep 30 & echo $! > "$1"; wait`, "sh", file).
+		WithGracePeriod(time.Second).
+		Output()
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Equal(t, "stopping\n", o.Text())
+
+	data, err := os.ReadFile(file)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return !alive(pid)
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+// alive reports whether the process exists and is not a zombie that
+// has not been reaped yet.
+func alive(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return true
+	}
+
+	i := strings.LastIndexByte(string(data), ')')
+	return i < 0 || !strings.HasPrefix(string(data[i+1:]), " Z")
+}
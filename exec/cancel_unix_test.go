@@ -0,0 +1,57 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package exec
+
+import (
+	"context"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextTerminalKeepsProcessGroup(t *testing.T) {
+	_, ok := Which("sleep")
+	if !ok {
+		t.Skip("sleep not found")
+	}
+
+	ptmx, tty, err := openPty(DefaultPtySize)
+	if err != nil {
+		t.Skip("pseudo-terminals not available")
+	}
+
+	defer ptmx.Close()
+	defer tty.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// a command reading from the terminal stays in the foreground group.
+	c := NewContext(ctx, "sleep", "10")
+	c.Cmd.Stdin = tty
+	if !assert.NoError(t, c.Start()) {
+		return
+	}
+
+	pgid, err := syscall.Getpgid(c.Pid())
+	assert.NoError(t, err)
+	assert.Equal(t, syscall.Getpgrp(), pgid)
+	cancel()
+	assert.Error(t, c.Wait())
+
+	// without a terminal it runs in its own group.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	c = NewContext(ctx, "sleep", "10")
+	if !assert.NoError(t, c.Start()) {
+		return
+	}
+
+	pgid, err = syscall.Getpgid(c.Pid())
+	assert.NoError(t, err)
+	assert.Equal(t, c.Pid(), pgid)
+	cancel()
+	assert.Error(t, c.Wait())
+}
@@ -0,0 +1,168 @@
+package exec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const snippetSize = 40
+
+// DecodeError is returned when the output of a command could not be
+// decoded. Snippet holds the output around the offending position.
+type DecodeError struct {
+	Format  string
+	Line    int // 1-based line of the output, 0 if unknown
+	Offset  int64
+	Snippet string
+	Err     error
+}
+
+func (e *DecodeError) Error() string {
+	msg := "failed to decode " + e.Format
+	if e.Line > 0 {
+		msg += fmt.Sprintf(" on line %d", e.Line)
+	}
+
+	return fmt.Sprintf("%s: %v near %q", msg, e.Err, e.Snippet)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeJson unmarshals stdout as JSON into v.
+func (o *Result) DecodeJson(v interface{}) error {
+	if err := json.Unmarshal(o.Stdout, v); err != nil {
+		return newJsonDecodeError(o.Stdout, 0, err)
+	}
+
+	return nil
+}
+
+// Decode unmarshals the JSON stdout of the result into a T.
+//
+//	type status struct{ Branch string }
+//	s, err := exec.Decode[status](res)
+func Decode[T any](o *Result) (T, error) {
+	var v T
+	err := o.DecodeJson(&v)
+	return v, err
+}
+
+// DecodeJsonLines unmarshals each non-empty line of newline-delimited
+// JSON (NDJSON) stdout into a T.
+func DecodeJsonLines[T any](o *Result) ([]T, error) {
+	set := make([]T, 0)
+	err := EachJsonLine(o, func(v T) error {
+		set = append(set, v)
+		return nil
+	})
+
+	return set, err
+}
+
+// EachJsonLine unmarshals each non-empty line of newline-delimited
+// JSON (NDJSON) stdout into a T and passes it to fn. Iteration stops
+// at the first error.
+func EachJsonLine[T any](o *Result, fn func(v T) error) error {
+	for i, line := range bytes.Split(o.Stdout, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		var v T
+		if err := json.Unmarshal(line, &v); err != nil {
+			return newJsonDecodeError(line, i+1, err)
+		}
+
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// KeyValues parses stdout made of key=value lines, e.g. the output of
+// `git config -l` or `env`. Blank lines and lines starting with # are
+// skipped. When a key repeats, the last value wins.
+func (o *Result) KeyValues() (map[string]string, error) {
+	set := make(map[string]string)
+	for i, line := range strings.Split(string(o.Stdout), "\n") {
+		line = strings.TrimRight(line, "\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		k, v, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, &DecodeError{
+				Format:  "key=value",
+				Line:    i + 1,
+				Snippet: snippet([]byte(line), 0),
+				Err:     errors.New("expected key=value"),
+			}
+		}
+
+		set[strings.TrimSpace(k)] = v
+	}
+
+	return set, nil
+}
+
+func newJsonDecodeError(data []byte, line int, err error) error {
+	var offset int64
+	var se *json.SyntaxError
+	var te *json.UnmarshalTypeError
+	if errors.As(err, &se) {
+		offset = se.Offset
+	} else if errors.As(err, &te) {
+		offset = te.Offset
+	}
+
+	if line == 0 && offset > 0 {
+		line = bytes.Count(data[:offset-1], []byte("\n")) + 1
+	}
+
+	return &DecodeError{
+		Format:  "json",
+		Line:    line,
+		Offset:  offset,
+		Snippet: snippet(data, offset),
+		Err:     err,
+	}
+}
+
+// snippet returns the data around offset, trimmed to snippetSize
+// bytes on each side.
+func snippet(data []byte, offset int64) string {
+	start := int(offset) - snippetSize
+	if start < 0 {
+		start = 0
+	}
+
+	end := int(offset) + snippetSize
+	if end > len(data) {
+		end = len(data)
+	}
+
+	if start > end {
+		start = end
+	}
+
+	s := string(data[start:end])
+	if start > 0 {
+		s = "..." + s
+	}
+
+	if end < len(data) {
+		s += "..."
+	}
+
+	return s
+}
@@ -0,0 +1,67 @@
+package exec_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hyprxlabs/go/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+type decodeItem struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestDecode(t *testing.T) {
+	res := &exec.Result{Stdout: []byte(`{"name":"a","count":2}`)}
+	v, err := exec.Decode[decodeItem](res)
+	assert.NoError(t, err)
+	assert.Equal(t, decodeItem{Name: "a", Count: 2}, v)
+
+	res = &exec.Result{Stdout: []byte("{\n  \"name\": \"a\",\n  \"count\": \"two\"\n}")}
+	_, err = exec.Decode[decodeItem](res)
+	var de *exec.DecodeError
+	if assert.True(t, errors.As(err, &de)) {
+		assert.Equal(t, 3, de.Line)
+		assert.Contains(t, de.Snippet, `"count": "two"`)
+	}
+}
+
+func TestDecodeJsonLines(t *testing.T) {
+	res := &exec.Result{Stdout: []byte("{\"name\":\"a\",\"count\":1}\n\n{\"name\":\"b\",\"count\":2}\n")}
+	items, err := exec.DecodeJsonLines[decodeItem](res)
+	assert.NoError(t, err)
+	assert.Equal(t, []decodeItem{{"a", 1}, {"b", 2}}, items)
+
+	res = &exec.Result{Stdout: []byte("{\"name\":\"a\"}\nnot json\n")}
+	_, err = exec.DecodeJsonLines[decodeItem](res)
+	var de *exec.DecodeError
+	if assert.True(t, errors.As(err, &de)) {
+		assert.Equal(t, 2, de.Line)
+		assert.Equal(t, "not json", de.Snippet)
+	}
+
+	stop := errors.New("stop")
+	n := 0
+	err = exec.EachJsonLine(&exec.Result{Stdout: []byte("{}\n{}\n")}, func(v decodeItem) error {
+		n++
+		return stop
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 1, n)
+}
+
+func TestKeyValues(t *testing.T) {
+	res := &exec.Result{Stdout: []byte("user.name=Jane Doe\r\n# comment\n\ncore.editor=vim --noplugin\nalias.eq=a=b\n")}
+	kv, err := res.KeyValues()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"user.name":   "Jane Doe",
+		"core.editor": "vim --noplugin",
+		"alias.eq":    "a=b",
+	}, kv)
+
+	_, err = (&exec.Result{Stdout: []byte("a=1\nbroken line\n")}).KeyValues()
+	assert.EqualError(t, err, `failed to decode key=value on line 2: expected key=value near "broken line"`)
+}
@@ -0,0 +1,229 @@
+package exec
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyprxlabs/go/cmdargs"
+)
+
+var (
+	dryRun       bool
+	dryRunOutput io.Writer = os.Stderr
+	recorder     *Recorder
+)
+
+// SetDryRun enables or disables dry-run mode for all commands. In
+// dry-run mode Run, Output, Quiet, RunAndCapture and pipelines do not
+// spawn processes. Instead the resolved command is written to the
+// dry-run output and a successful synthetic Result is returned.
+func SetDryRun(enabled bool) {
+	dryRun = enabled
+}
+
+// IsDryRun reports whether dry-run mode is enabled for all commands.
+func IsDryRun() bool {
+	return dryRun
+}
+
+// SetDryRunOutput sets where dry-run mode logs commands.
+// Defaults to os.Stderr.
+func SetDryRunOutput(w io.Writer) {
+	dryRunOutput = w
+}
+
+// SetRecorder sets the recorder that captures every command that is
+// run or, in dry-run mode, would have been run. Pass nil to stop
+// recording.
+func SetRecorder(r *Recorder) {
+	recorder = r
+}
+
+// WithDryRun enables dry-run mode for this command.
+func (c *Cmd) WithDryRun(enabled bool) *Cmd {
+	c.dryRun = enabled
+	return c
+}
+
+// WithRecorder sets a recorder for this command in addition to the
+// package level recorder.
+func (c *Cmd) WithRecorder(r *Recorder) *Cmd {
+	c.recorder = r
+	return c
+}
+
+func (c *Cmd) isDryRun() bool {
+	return c.dryRun || dryRun
+}
+
+// Record describes a command that was run or would have been run.
+type Record struct {
+	Path       string            `json:"path"`
+	Args       []string          `json:"args"`
+	Dir        string            `json:"dir,omitempty"`
+	Env        map[string]string `json:"env,omitempty"`
+	EnvRemoved []string          `json:"envRemoved,omitempty"`
+	DryRun     bool              `json:"dryRun"`
+	Code       int               `json:"code"`
+	StartedAt  time.Time         `json:"startedAt"`
+	EndedAt    time.Time         `json:"endedAt"`
+}
+
+// String formats the record as a command line.
+func (r Record) String() string {
+	sb := strings.Builder{}
+	args := r.Args
+	if len(args) > 0 {
+		args = append([]string{r.Path}, args[1:]...)
+	}
+
+	sb.WriteString(cmdargs.New(args).String())
+	if r.Dir != "" {
+		sb.WriteString(" (cwd: " + r.Dir + ")")
+	}
+
+	keys := make([]string, 0, len(r.Env))
+	for k := range r.Env {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		sb.WriteString(" +" + k + "=" + r.Env[k])
+	}
+
+	for _, k := range r.EnvRemoved {
+		sb.WriteString(" -" + k)
+	}
+
+	return sb.String()
+}
+
+// Recorder collects the commands that were run. It is safe for
+// concurrent use.
+type Recorder struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{records: make([]Record, 0)}
+}
+
+func (r *Recorder) Add(record Record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = append(r.records, record)
+}
+
+// Records returns a copy of the recorded commands in the order they
+// were run.
+func (r *Recorder) Records() []Record {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	set := make([]Record, len(r.records))
+	copy(set, r.records)
+	return set
+}
+
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = make([]Record, 0)
+}
+
+func (r *Recorder) Json() ([]byte, error) {
+	return json.MarshalIndent(r.Records(), "", "  ")
+}
+
+// SaveJson writes the recorded commands to a JSON file.
+func (r *Recorder) SaveJson(path string) error {
+	data, err := r.Json()
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o644)
+}
+
+// newRecord describes the command with the path resolved and the
+// environment reduced to the differences from the current process.
+func (c *Cmd) newRecord(dry bool) Record {
+	c.resolvePath()
+	restoreEnv := c.applyEnv()
+	defer restoreEnv()
+	m := c.masked()
+	r := Record{
+		Path:   m.Cmd.Path,
+		Args:   m.Cmd.Args,
+		Dir:    c.Cmd.Dir,
+		DryRun: dry,
+	}
+
+	if r.Dir == "" {
+		r.Dir, _ = os.Getwd()
+	} else if abs, err := filepath.Abs(r.Dir); err == nil {
+		r.Dir = abs
+	}
+
+	if m.Cmd.Env != nil {
+		r.Env, r.EnvRemoved = envDiff(m.Cmd.Env)
+	}
+
+	return r
+}
+
+func (c *Cmd) record(out *Result, dry bool) {
+	if c.recorder == nil && recorder == nil {
+		return
+	}
+
+	r := c.newRecord(dry)
+	r.Code = out.Code
+	r.StartedAt = out.StartedAt
+	r.EndedAt = out.EndedAt
+	if c.recorder != nil {
+		c.recorder.Add(r)
+	}
+
+	if recorder != nil && recorder != c.recorder {
+		recorder.Add(r)
+	}
+}
+
+// dryRunResult logs the command and returns a synthetic result.
+func (c *Cmd) dryRunResult() *Result {
+	now := time.Now().UTC()
+	out := &Result{
+		FileName:  c.Cmd.Path,
+		Args:      c.Cmd.Args,
+		Stdout:    make([]byte, 0),
+		Stderr:    make([]byte, 0),
+		StartedAt: now,
+		EndedAt:   now,
+		DryRun:    true,
+	}
+
+	c.record(out, true)
+	return out
+}
+
+func logDryRun(cmds ...*Cmd) {
+	if dryRunOutput == nil {
+		return
+	}
+
+	parts := make([]string, len(cmds))
+	for i, c := range cmds {
+		parts[i] = c.newRecord(true).String()
+	}
+
+	_, _ = fmt.Fprintln(dryRunOutput, "[dry-run] "+strings.Join(parts, " | "))
+}
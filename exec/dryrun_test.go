@@ -0,0 +1,67 @@
+package exec_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyprxlabs/go/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRun(t *testing.T) {
+	var log bytes.Buffer
+	exec.SetDryRunOutput(&log)
+	defer exec.SetDryRunOutput(os.Stderr)
+
+	r := exec.NewRecorder()
+	dir := t.TempDir()
+	o, err := exec.New("definitely-not-a-real-command-xyz", "hello world").
+		WithCwd(dir).
+		WithEnv(append(os.Environ(), "EXEC_DRY_RUN_TEST=1")...).
+		WithDryRun(true).
+		WithRecorder(r).
+		Output()
+
+	assert.NoError(t, err)
+	assert.True(t, o.DryRun)
+	assert.Equal(t, 0, o.Code)
+	assert.Contains(t, log.String(), `[dry-run] definitely-not-a-real-command-xyz "hello world"`)
+	assert.Contains(t, log.String(), "+EXEC_DRY_RUN_TEST=1")
+
+	records := r.Records()
+	assert.Len(t, records, 1)
+	assert.True(t, records[0].DryRun)
+	assert.Equal(t, dir, records[0].Dir)
+	assert.Equal(t, map[string]string{"EXEC_DRY_RUN_TEST": "1"}, records[0].Env)
+
+	file := filepath.Join(dir, "records.json")
+	assert.NoError(t, r.SaveJson(file))
+	data, err := os.ReadFile(file)
+	assert.NoError(t, err)
+
+	var decoded []exec.Record
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Len(t, decoded, 1)
+}
+
+func TestRecorderCapturesRuns(t *testing.T) {
+	_, ok := exec.Which("echo")
+	if !ok {
+		t.Skip("echo not found")
+	}
+
+	r := exec.NewRecorder()
+	exec.SetRecorder(r)
+	defer exec.SetRecorder(nil)
+
+	_, err := exec.Command("echo hello").PipeCommand("cat").Output()
+	assert.NoError(t, err)
+
+	records := r.Records()
+	assert.Len(t, records, 2)
+	assert.False(t, records[0].DryRun)
+	assert.Equal(t, []string{"echo", "hello"}, records[0].Args)
+}
@@ -0,0 +1,225 @@
+package exec
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	ENV_INHERIT   = 0 // inherit the current process environment
+	ENV_CLEAN     = 1 // start from an empty environment
+	ENV_ALLOWLIST = 2 // inherit only the allowed keys
+)
+
+type envVar struct {
+	key   string
+	value string
+}
+
+// WithEnvMode sets how the command inherits the environment of the
+// current process. SetEnv and UnsetEnv are applied on top of it.
+//
+// Variables set with WithEnv or WithEnvMap replace the inherited
+// environment in every mode. The environment is resolved each time
+// the command starts.
+func (c *Cmd) WithEnvMode(mode int) *Cmd {
+	c.envMode = mode
+	return c
+}
+
+// WithInheritedEnv inherits only the given keys from the current
+// process environment. A key ending in "*" matches by prefix,
+// e.g. "LC_*".
+func (c *Cmd) WithInheritedEnv(keys ...string) *Cmd {
+	c.envMode = ENV_ALLOWLIST
+	c.envAllow = keys
+	return c
+}
+
+// SetEnv sets an environment variable for the command, replacing
+// any previous value for the key.
+func (c *Cmd) SetEnv(key, value string) *Cmd {
+	k := envKey(key)
+	if c.envSet == nil {
+		c.envSet = make(map[string]envVar)
+	}
+
+	c.envSet[k] = envVar{key: key, value: value}
+	delete(c.envUnset, k)
+	return c
+}
+
+// UnsetEnv removes an environment variable from the command.
+func (c *Cmd) UnsetEnv(key string) *Cmd {
+	k := envKey(key)
+	if c.envUnset == nil {
+		c.envUnset = make(map[string]bool)
+	}
+
+	c.envUnset[k] = true
+	delete(c.envSet, k)
+	return c
+}
+
+// Environ returns the environment the command runs with. When an env
+// mode, SetEnv, UnsetEnv, AppendEnv or PrependEnv is used the
+// variables are deduplicated and sorted by key.
+func (c *Cmd) Environ() []string {
+	if !c.hasEnvOverlay() {
+		if c.Cmd.Env != nil {
+			return c.Cmd.Env
+		}
+
+		return os.Environ()
+	}
+
+	base := c.baseEnv()
+	vars := make(map[string]envVar, len(base)+len(c.envSet))
+	for _, env := range [][]string{c.envPrepend, base, c.envAppend} {
+		for _, kv := range env {
+			k, v := splitEnv(kv)
+			vars[envKey(k)] = envVar{key: k, value: v}
+		}
+	}
+
+	for k, v := range c.envSet {
+		vars[k] = v
+	}
+
+	for k := range c.envUnset {
+		delete(vars, k)
+	}
+
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	env := make([]string, len(keys))
+	for i, k := range keys {
+		env[i] = vars[k].key + "=" + vars[k].value
+	}
+
+	return env
+}
+
+// EnvDiff compares the command's environment with the current process
+// environment and returns the variables that are added or changed and
+// the keys that are removed.
+func (c *Cmd) EnvDiff() (map[string]string, []string) {
+	return envDiff(c.Environ())
+}
+
+// baseEnv returns the explicitly set environment or the inherited
+// one for the env mode.
+func (c *Cmd) baseEnv() []string {
+	if c.Cmd.Env != nil || c.envMode == ENV_CLEAN {
+		return c.Cmd.Env
+	}
+
+	env := os.Environ()
+	if c.envMode != ENV_ALLOWLIST {
+		return env
+	}
+
+	allowed := make([]string, 0, len(c.envAllow))
+	for _, kv := range env {
+		if k, _ := splitEnv(kv); c.envAllowed(k) {
+			allowed = append(allowed, kv)
+		}
+	}
+
+	return allowed
+}
+
+func (c *Cmd) envAllowed(key string) bool {
+	k := envKey(key)
+	for _, allow := range c.envAllow {
+		allow = envKey(allow)
+		if strings.HasSuffix(allow, "*") {
+			if strings.HasPrefix(k, allow[:len(allow)-1]) {
+				return true
+			}
+			continue
+		}
+
+		if k == allow {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasEnvOverlay reports whether the environment differs from the
+// configured Env or the inherited one.
+func (c *Cmd) hasEnvOverlay() bool {
+	return c.envMode != ENV_INHERIT || c.envSet != nil || c.envUnset != nil ||
+		c.envAppend != nil || c.envPrepend != nil
+}
+
+// applyEnv resolves the environment into the underlying command for
+// one launch and returns a func that restores the configured Env.
+func (c *Cmd) applyEnv() func() {
+	if !c.hasEnvOverlay() {
+		return func() {}
+	}
+
+	cmd := c.Cmd
+	env := cmd.Env
+	cmd.Env = c.Environ()
+	return func() {
+		cmd.Env = env
+	}
+}
+
+func envDiff(env []string) (map[string]string, []string) {
+	current := envMap(os.Environ())
+	next := envMap(env)
+	var set map[string]string
+	for k, v := range next {
+		if cv, ok := current[k]; !ok || cv.value != v.value {
+			if set == nil {
+				set = make(map[string]string)
+			}
+			set[v.key] = v.value
+		}
+	}
+
+	var removed []string
+	for k, v := range current {
+		if _, ok := next[k]; !ok {
+			removed = append(removed, v.key)
+		}
+	}
+
+	sort.Strings(removed)
+	return set, removed
+}
+
+func envMap(env []string) map[string]envVar {
+	m := make(map[string]envVar, len(env))
+	for _, kv := range env {
+		k, v := splitEnv(kv)
+		m[envKey(k)] = envVar{key: k, value: v}
+	}
+
+	return m
+}
+
+// splitEnv splits KEY=VALUE. Windows has hidden variables such as
+// "=C:=C:\dir" whose key starts with "=".
+func splitEnv(kv string) (string, string) {
+	if kv == "" {
+		return "", ""
+	}
+
+	i := strings.Index(kv[1:], "=")
+	if i < 0 {
+		return kv, ""
+	}
+
+	return kv[:i+1], kv[i+2:]
+}
@@ -0,0 +1,103 @@
+package exec_test
+
+import (
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/hyprxlabs/go/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvModes(t *testing.T) {
+	t.Setenv("EXEC_ENV_KEEP", "keep")
+	t.Setenv("EXEC_ENV_DROP", "drop")
+
+	env := exec.New("env").SetEnv("EXEC_ENV_NEW", "1").UnsetEnv("EXEC_ENV_DROP").Environ()
+	assert.Contains(t, env, "EXEC_ENV_KEEP=keep")
+	assert.Contains(t, env, "EXEC_ENV_NEW=1")
+	assert.NotContains(t, env, "EXEC_ENV_DROP=drop")
+	assert.IsIncreasing(t, env)
+
+	env = exec.New("env").WithEnvMode(exec.ENV_CLEAN).SetEnv("B", "2").SetEnv("A", "1").SetEnv("B", "3").Environ()
+	assert.Equal(t, []string{"A=1", "B=3"}, env)
+
+	env = exec.New("env").WithInheritedEnv("EXEC_ENV_K*").SetEnv("A", "1").Environ()
+	assert.Equal(t, []string{"A=1", "EXEC_ENV_KEEP=keep"}, env)
+
+	env = exec.New("env").WithInheritedEnv("EXEC_ENV_KEEP").AppendEnv("A=1").Environ()
+	assert.Equal(t, []string{"A=1", "EXEC_ENV_KEEP=keep"}, env)
+}
+
+func TestEnvAppendInherits(t *testing.T) {
+	t.Setenv("EXEC_ENV_KEEP", "keep")
+	c := exec.New("env").AppendEnv("EXEC_ENV_NEW=1")
+	assert.Contains(t, c.Environ(), "EXEC_ENV_KEEP=keep")
+	assert.Contains(t, c.Environ(), "EXEC_ENV_NEW=1")
+	assert.Nil(t, c.Env)
+
+	// the order of AppendEnv and the env mode does not matter.
+	c = exec.New("env").AppendEnv("A=1").WithInheritedEnv("EXEC_ENV_KEEP")
+	assert.Equal(t, []string{"A=1", "EXEC_ENV_KEEP=keep"}, c.Environ())
+
+	c = exec.New("env").PrependEnv("EXEC_ENV_KEEP=default", "B=2").WithEnvMode(exec.ENV_ALLOWLIST)
+	assert.Equal(t, []string{"B=2", "EXEC_ENV_KEEP=default"}, c.Environ())
+
+	c = exec.New("env").WithEnvMap(map[string]string{"C": "3", "A": "1", "B": "2"})
+	assert.Equal(t, []string{"A=1", "B=2", "C=3"}, c.Env)
+}
+
+func TestEnvDiff(t *testing.T) {
+	t.Setenv("EXEC_ENV_DROP", "drop")
+	set, removed := exec.New("env").SetEnv("EXEC_ENV_NEW", "1").UnsetEnv("EXEC_ENV_DROP").EnvDiff()
+	assert.Equal(t, map[string]string{"EXEC_ENV_NEW": "1"}, set)
+	assert.Equal(t, []string{"EXEC_ENV_DROP"}, removed)
+}
+
+func TestEnvCaseInsensitiveOnWindows(t *testing.T) {
+	if runtime.GOOS != "windows" {
+		t.Skip("windows only")
+	}
+
+	env := exec.New("cmd").WithEnvMode(exec.ENV_CLEAN).SetEnv("Path", "a").SetEnv("PATH", "b").Environ()
+	assert.Equal(t, []string{"PATH=b"}, env)
+}
+
+func TestEnvRun(t *testing.T) {
+	_, ok := exec.Which("sh")
+	if !ok {
+		t.Skip("sh not found")
+	}
+
+	t.Setenv("EXEC_ENV_DROP", "drop")
+	o, err := exec.New("sh", "-c", "echo ${EXEC_ENV_NEW}${EXEC_ENV_DROP}").
+		SetEnv("EXEC_ENV_NEW", "new").
+		UnsetEnv("EXEC_ENV_DROP").
+		Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "new", o.Lines()[0])
+	assert.Equal(t, "drop", os.Getenv("EXEC_ENV_DROP"))
+}
+
+func TestEnvResolvedPerRun(t *testing.T) {
+	_, ok := exec.Which("sh")
+	if !ok {
+		t.Skip("sh not found")
+	}
+
+	t.Setenv("EXEC_ENV_KEEP", "first")
+	c := exec.New("sh", "-c", "echo ${EXEC_ENV_KEEP}-${EXEC_ENV_NEW}").SetEnv("EXEC_ENV_NEW", "1")
+	o, err := c.Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "first-1", o.Lines()[0])
+	assert.Nil(t, c.Env)
+
+	os.Setenv("EXEC_ENV_KEEP", "second")
+	o, err = c.SetEnv("EXEC_ENV_NEW", "2").Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "second-2", o.Lines()[0])
+
+	o, err = c.WithEnvMode(exec.ENV_CLEAN).Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "-2", o.Lines()[0])
+}
@@ -0,0 +1,84 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"time"
+)
+
+// StartError is returned when a command could not be started.
+type StartError struct {
+	FileName string
+	Err      error
+}
+
+func (e *StartError) Error() string {
+	return fmt.Sprintf("failed to start %s: %v", e.FileName, e.Err)
+}
+
+func (e *StartError) Unwrap() error {
+	return e.Err
+}
+
+// NotFoundError is returned when the executable for a command
+// could not be found.
+type NotFoundError struct {
+	Name string
+	Err  error
+}
+
+func (e *NotFoundError) Error() string {
+	return "executable not found: " + e.Name
+}
+
+func (e *NotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// ExitError is returned when a command ran but exited with a
+// non-zero exit code or was terminated by a signal.
+type ExitError struct {
+	FileName string
+	Code     int
+	Signal   string
+	TimedOut bool
+	Timeout  time.Duration
+	Err      error
+}
+
+func (e *ExitError) Error() string {
+	msg := fmt.Sprintf("command %s exited with code %d", e.FileName, e.Code)
+	if e.TimedOut {
+		msg = fmt.Sprintf("command %s timed out after %s", e.FileName, e.Timeout)
+	}
+
+	if e.Signal != "" {
+		msg += " (" + e.Signal + ")"
+	}
+
+	return msg
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+func newStartError(name string, err error) error {
+	var ee *exec.Error
+	var pe *fs.PathError
+	if errors.As(err, &pe) && pe.Op == "chdir" {
+		return &StartError{FileName: name, Err: err}
+	}
+
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+		return &NotFoundError{Name: name, Err: err}
+	}
+
+	if errors.As(err, &ee) {
+		return &StartError{FileName: name, Err: ee.Err}
+	}
+
+	return &StartError{FileName: name, Err: err}
+}
@@ -0,0 +1,47 @@
+package exec_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hyprxlabs/go/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCodeIsPreserved(t *testing.T) {
+	_, ok := exec.Which("sh")
+	if !ok {
+		t.Skip("sh not found")
+	}
+
+	o, err := exec.New("sh", "-c", "echo out; echo err >&2; exit 3").Output()
+	assert.Error(t, err)
+	assert.Equal(t, 3, o.Code)
+	assert.Equal(t, "out\n", o.Text())
+	assert.Equal(t, "err\n", o.ErrorText())
+
+	var ee *exec.ExitError
+	assert.True(t, errors.As(err, &ee))
+	assert.Equal(t, 3, ee.Code)
+}
+
+func TestIgnoreExitCode(t *testing.T) {
+	_, ok := exec.Which("sh")
+	if !ok {
+		t.Skip("sh not found")
+	}
+
+	o, err := exec.New("sh", "-c", "exit 4").WithIgnoreExitCode(true).Quiet()
+	assert.NoError(t, err)
+	assert.Equal(t, 4, o.Code)
+	assert.False(t, o.IsOk())
+}
+
+func TestNotFoundError(t *testing.T) {
+	o, err := exec.New("definitely-not-a-real-command-xyz").Output()
+	assert.Error(t, err)
+	assert.Equal(t, -1, o.Code)
+
+	var nf *exec.NotFoundError
+	assert.True(t, errors.As(err, &nf))
+}
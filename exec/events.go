@@ -0,0 +1,181 @@
+package exec
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+const (
+	EVENT_START  = "start"
+	EVENT_OUTPUT = "output"
+	EVENT_EXIT   = "exit"
+	EVENT_ERROR  = "error"
+)
+
+// Event describes a step in the life of a command. Args and output
+// are masked when a masker is configured.
+type Event struct {
+	Type string
+	Time time.Time
+	Path string
+	Args []string
+	Dir  string
+	Pid  int
+	// Stream is "stdout" or "stderr" for output events and Data the
+	// chunk that was written.
+	Stream string
+	Data   []byte
+	// Code, Signal and Duration are set for exit events. Err is set
+	// for error events and for exit events with a non-zero code.
+	Code     int
+	Signal   string
+	Duration time.Duration
+	Err      error
+}
+
+// EventHandler receives command events. It may be called from
+// multiple goroutines at the same time.
+type EventHandler func(e Event)
+
+var (
+	eventHandler EventHandler
+)
+
+// SetEventHandler sets the handler that receives the events of all
+// commands. Pass nil to stop emitting events.
+func SetEventHandler(h EventHandler) {
+	eventHandler = h
+}
+
+// OnEvent sets a handler for the events of this command in addition
+// to the package level handler.
+func (c *Cmd) OnEvent(h EventHandler) *Cmd {
+	c.onEvent = h
+	return c
+}
+
+func (c *Cmd) hasEventHandler() bool {
+	return c.onEvent != nil || eventHandler != nil
+}
+
+func (c *Cmd) emit(e Event) {
+	if !c.hasEventHandler() {
+		return
+	}
+
+	s := c.eventSource
+	if s == nil {
+		s = c.newEventSource()
+	}
+
+	e.Time = time.Now().UTC()
+	e.Path = s.path
+	e.Args = s.args
+	e.Dir = s.dir
+	if c.Cmd.Process != nil {
+		e.Pid = c.Cmd.Process.Pid
+	}
+
+	if c.onEvent != nil {
+		c.onEvent(e)
+	}
+
+	if eventHandler != nil {
+		eventHandler(e)
+	}
+}
+
+// eventSource is the masked command reported in events.
+type eventSource struct {
+	path string
+	args []string
+	dir  string
+}
+
+func (c *Cmd) newEventSource() *eventSource {
+	m := c.masked()
+	return &eventSource{path: m.Cmd.Path, args: m.Cmd.Args, dir: c.Cmd.Dir}
+}
+
+// snapshotEvents masks the command once before it starts. Output
+// events are emitted from the goroutines copying the output, which
+// must not read the command while Start restores its environment.
+func (c *Cmd) snapshotEvents() {
+	c.eventSource = nil
+	if c.hasEventHandler() {
+		c.eventSource = c.newEventSource()
+	}
+}
+
+// emitStarted emits the start event, or an error event when the
+// command failed to start.
+func (c *Cmd) emitStarted(err error) {
+	if err != nil {
+		c.emit(Event{Type: EVENT_ERROR, Code: -1, Err: err})
+		return
+	}
+
+	c.emit(Event{Type: EVENT_START})
+}
+
+// emitExited emits the exit event once the command has been waited
+// for. Errors other than a non-zero exit are emitted as error events.
+func (c *Cmd) emitExited(code int, signal string, err error) {
+	if !c.hasEventHandler() {
+		return
+	}
+
+	e := Event{
+		Type:     EVENT_EXIT,
+		Code:     code,
+		Signal:   signal,
+		Duration: time.Since(c.startedAt),
+		Err:      err,
+	}
+
+	var ee *ExitError
+	if err != nil && !errors.As(err, &ee) {
+		e.Type = EVENT_ERROR
+	}
+
+	c.emit(e)
+}
+
+// tapEvents routes the output through output events before the
+// command starts.
+func (c *Cmd) tapEvents() {
+	c.eventSource = nil
+	if !c.hasEventHandler() {
+		return
+	}
+
+	c.Cmd.Stdout = &eventWriter{c: c, stream: "stdout", next: c.Cmd.Stdout}
+	if c.interactive {
+		// keep a single writer, the merged output is reported as stdout.
+		c.Cmd.Stderr = c.Cmd.Stdout
+		return
+	}
+
+	c.Cmd.Stderr = &eventWriter{c: c, stream: "stderr", next: c.Cmd.Stderr}
+}
+
+type eventWriter struct {
+	c      *Cmd
+	stream string
+	next   io.Writer
+}
+
+func (w *eventWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		data := make([]byte, len(p))
+		copy(data, p)
+		w.c.emit(Event{Type: EVENT_OUTPUT, Stream: w.stream, Data: data})
+	}
+
+	if w.next == nil {
+		return len(p), nil
+	}
+
+	return w.next.Write(p)
+}
@@ -0,0 +1,107 @@
+package exec_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hyprxlabs/go/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+type eventLog struct {
+	mu     sync.Mutex
+	events []exec.Event
+}
+
+func (l *eventLog) handle(e exec.Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, e)
+}
+
+func (l *eventLog) types() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	set := make([]string, 0)
+	for _, e := range l.events {
+		if len(set) == 0 || set[len(set)-1] != e.Type {
+			set = append(set, e.Type)
+		}
+	}
+
+	return set
+}
+
+func TestEvents(t *testing.T) {
+	_, ok := exec.Which("sh")
+	if !ok {
+		t.Skip("sh not found")
+	}
+
+	var log eventLog
+	started := 0
+	exec.SetLogger(func(cmd *exec.Cmd) { started++ })
+	defer exec.SetLogger(nil)
+
+	o, err := exec.New("sh", "-c", "echo out; exit 3").OnEvent(log.handle).Output()
+	assert.Error(t, err)
+	assert.Equal(t, "out\n", o.Text())
+	assert.Equal(t, 1, started)
+	assert.Equal(t, []string{exec.EVENT_START, exec.EVENT_OUTPUT, exec.EVENT_EXIT}, log.types())
+
+	last := log.events[len(log.events)-1]
+	assert.Equal(t, 3, last.Code)
+	assert.Error(t, last.Err)
+	assert.Greater(t, last.Duration.Nanoseconds(), int64(0))
+	assert.NotZero(t, log.events[0].Pid)
+	assert.Equal(t, "stdout", log.events[1].Stream)
+	assert.Equal(t, "out\n", string(log.events[1].Data))
+}
+
+func TestEventsStartError(t *testing.T) {
+	var log eventLog
+	exec.SetEventHandler(log.handle)
+	defer exec.SetEventHandler(nil)
+
+	_, err := exec.New("definitely-not-a-real-command-xyz").Output()
+	assert.Error(t, err)
+	assert.Equal(t, []string{exec.EVENT_ERROR}, log.types())
+}
+
+func TestEventsRunner(t *testing.T) {
+	var log eventLog
+	fake := exec.NewFakeRunner()
+	fake.Expect("git", "status").Stdout("clean\n")
+
+	_, err := exec.New("git", "status").WithRunner(fake).OnEvent(log.handle).Output()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{exec.EVENT_START, exec.EVENT_OUTPUT, exec.EVENT_EXIT}, log.types())
+}
+
+func TestEventsMaskedEnv(t *testing.T) {
+	_, ok := exec.Which("sh")
+	if !ok {
+		t.Skip("sh not found")
+	}
+
+	var log eventLog
+	masker := maskFunc(func(s string) string {
+		return strings.ReplaceAll(s, "hunter2", "****")
+	})
+
+	// output events are emitted while Start restores the environment.
+	for i := 0; i < 5; i++ {
+		o, err := exec.New("sh", "-c", "for i in 1 2 3 4 5; do echo $TOKEN; done", "hunter2").
+			SetEnv("TOKEN", "hunter2").
+			WithMasker(masker).
+			OnEvent(log.handle).
+			Output()
+		assert.NoError(t, err)
+		assert.NotContains(t, o.Text(), "hunter2")
+	}
+
+	for _, e := range log.events {
+		assert.Equal(t, []string{"sh", "-c", "for i in 1 2 3 4 5; do echo $TOKEN; done", "****"}, e.Args)
+	}
+}
@@ -0,0 +1,271 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// ErrExpectTimeout is returned when the expected output does not
+// appear before the timeout.
+var ErrExpectTimeout = errors.New("timed out waiting for output")
+
+const (
+	// expectWindow is how much unconsumed output Expect matches against.
+	expectWindow = 64 << 10
+)
+
+// interactionLimit bounds the output an interaction keeps when the
+// command has no output limit.
+var interactionLimit = OutputLimit{Head: 64 << 10, Tail: 1 << 20}
+
+// Interaction scripts a running command's prompts: wait for output
+// that matches a pattern, then send a response.
+//
+//	i, err := exec.New("installer").Interact()
+//	_, err = i.Expect(`Continue\? \[y/N\]`)
+//	err = i.SendLine("y")
+//	res, err := i.Wait()
+//
+// Stdout and stderr are merged in the order the command writes them,
+// as on a terminal, and both are matched. Matched output is consumed
+// so the next Expect only sees output that follows the previous match,
+// and only the last 64 KiB of unconsumed output are matched. The output
+// kept for Output and Wait is bounded by WithOutputLimit, or to the
+// first 64 KiB and the last 1 MiB without a limit. With a masker, each
+// chunk of output is masked as it arrives, so a secret split across
+// two writes may not be masked.
+type Interaction struct {
+	cmd       *Cmd
+	stdin     io.WriteCloser
+	timeout   time.Duration
+	mu        sync.Mutex
+	pending   []byte
+	changed   chan struct{} // closed and replaced when output arrives
+	output    *capture
+	exited    chan struct{}
+	err       error
+	startedAt time.Time
+	endedAt   time.Time
+}
+
+type interactionWriter struct {
+	i    *Interaction
+	next io.Writer
+}
+
+func (w *interactionWriter) Write(p []byte) (int, error) {
+	w.i.mu.Lock()
+	_, _ = w.i.output.Write(p)
+	w.i.pending = append(w.i.pending, p...)
+	if len(w.i.pending) > expectWindow {
+		w.i.pending = append(w.i.pending[:0], w.i.pending[len(w.i.pending)-expectWindow:]...)
+	}
+	close(w.i.changed)
+	w.i.changed = make(chan struct{})
+	w.i.mu.Unlock()
+	if w.next != nil {
+		_, _ = w.next.Write(p)
+	}
+
+	return len(p), nil
+}
+
+// Interact starts the command with its stdin connected to the
+// interaction. Output is still written to the command's stdout writer
+// when it is set. Expect times out after 30 seconds
+// unless WithTimeout is used.
+func (c *Cmd) Interact() (*Interaction, error) {
+	i := &Interaction{
+		cmd:     c,
+		timeout: 30 * time.Second,
+		changed: make(chan struct{}),
+		exited:  make(chan struct{}),
+	}
+
+	if c.getRunner() != nil {
+		return nil, &StartError{FileName: c.Cmd.Path, Err: ErrRunnerStart}
+	}
+
+	limit := c.outputLimit
+	if limit == nil {
+		limit = &interactionLimit
+	}
+
+	output, err := newCapture(limit, "stdout")
+	if err != nil {
+		return nil, newStartError(c.Cmd.Path, err)
+	}
+
+	i.output = output
+	// the stdio is rewired for this interaction only, restore keeps
+	// later runs of the command unaffected.
+	stdin, stdout, stderr := c.Cmd.Stdin, c.Cmd.Stdout, c.Cmd.Stderr
+	restore := func() {
+		c.Cmd.Stdin, c.Cmd.Stdout, c.Cmd.Stderr = stdin, stdout, stderr
+		c.interactive = false
+	}
+
+	c.Cmd.Stdin = nil
+	pipe, err := c.Cmd.StdinPipe()
+	if err != nil {
+		restore()
+		output.discard()
+		return nil, err
+	}
+
+	i.stdin = pipe
+	// the same writer makes os/exec use a single pipe for both streams
+	// which keeps their order.
+	w := &interactionWriter{i: i, next: stdout}
+	c.Cmd.Stdout = w
+	c.Cmd.Stderr = w
+	c.interactive = true
+	i.startedAt = time.Now().UTC()
+	if err := c.Start(); err != nil {
+		restore()
+		output.discard()
+		return nil, err
+	}
+
+	go func() {
+		err := c.Wait()
+		restore()
+		i.mu.Lock()
+		i.err = err
+		i.endedAt = time.Now().UTC()
+		close(i.exited)
+		i.mu.Unlock()
+	}()
+
+	return i, nil
+}
+
+// WithTimeout sets how long Expect waits for a match.
+func (i *Interaction) WithTimeout(d time.Duration) *Interaction {
+	i.timeout = d
+	return i
+}
+
+// Expect waits until the output matches the pattern and returns the
+// match followed by its submatches.
+func (i *Interaction) Expect(pattern string) ([]string, error) {
+	return i.ExpectTimeout(pattern, i.timeout)
+}
+
+// ExpectTimeout waits up to d until the output matches the pattern.
+func (i *Interaction) ExpectTimeout(pattern string, d time.Duration) ([]string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	for {
+		i.mu.Lock()
+		if loc := re.FindSubmatchIndex(i.pending); loc != nil {
+			groups := make([]string, len(loc)/2)
+			for g := range groups {
+				if loc[2*g] >= 0 {
+					groups[g] = string(i.pending[loc[2*g]:loc[2*g+1]])
+				}
+			}
+
+			i.pending = i.pending[loc[1]:]
+			i.mu.Unlock()
+			return groups, nil
+		}
+
+		changed := i.changed
+		i.mu.Unlock()
+		select {
+		case <-changed:
+		case <-i.exited:
+			// the last output may have arrived right before the exit.
+			i.mu.Lock()
+			matched := re.Match(i.pending)
+			i.mu.Unlock()
+			if matched {
+				continue
+			}
+
+			return nil, fmt.Errorf("command exited before output matched %q: %w", pattern, io.EOF)
+		case <-timer.C:
+			return nil, fmt.Errorf("%w %q after %s", ErrExpectTimeout, pattern, d)
+		}
+	}
+}
+
+// ExpectString waits until the output contains s.
+func (i *Interaction) ExpectString(s string) error {
+	_, err := i.Expect(regexp.QuoteMeta(s))
+	return err
+}
+
+// Send writes s to the command's stdin.
+func (i *Interaction) Send(s string) error {
+	_, err := io.WriteString(i.stdin, s)
+	return err
+}
+
+// SendLine writes s followed by a newline to the command's stdin.
+func (i *Interaction) SendLine(s string) error {
+	return i.Send(s + "\n")
+}
+
+// CloseStdin closes the command's stdin, e.g. to signal end of input.
+func (i *Interaction) CloseStdin() error {
+	return i.stdin.Close()
+}
+
+// Stop terminates the command, see Cmd.Stop.
+func (i *Interaction) Stop() {
+	i.cmd.Stop()
+}
+
+// Output returns the output received so far.
+func (i *Interaction) Output() string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return string(i.output.Bytes())
+}
+
+// Wait closes stdin, waits for the command to exit and returns its
+// result. The merged output is in the result's Stdout.
+func (i *Interaction) Wait() (*Result, error) {
+	_ = i.stdin.Close()
+	<-i.exited
+
+	c := i.cmd
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	out := &Result{
+		FileName:  c.Cmd.Path,
+		Args:      c.Cmd.Args,
+		StartedAt: i.startedAt,
+		EndedAt:   i.endedAt,
+		TimedOut:  c.TimedOut(),
+		Signal:    exitSignal(c.Cmd.ProcessState),
+		Code:      exitCode(c.Cmd.ProcessState),
+		Usage:     resourceUsage(c.Cmd.ProcessState),
+	}
+
+	stderr, _ := newCapture(nil, "stderr")
+	err := fillOutput(out, i.output, stderr)
+	out.Stdout = append([]byte{}, out.Stdout...)
+	c.record(out, false)
+	if i.err != nil {
+		err = i.err
+	}
+
+	var ee *ExitError
+	if i.err != nil && c.ignoreExitCode && !out.TimedOut && errors.As(i.err, &ee) {
+		return out, nil
+	}
+
+	return out, err
+}
@@ -0,0 +1,191 @@
+package exec_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyprxlabs/go/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStdinHelpers(t *testing.T) {
+	_, ok := exec.Which("cat")
+	if !ok {
+		t.Skip("cat not found")
+	}
+
+	o, err := exec.New("cat").WithStdinString("hello").Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", o.Text())
+
+	o, err = exec.New("cat").WithStdinBytes([]byte("bytes")).Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "bytes", o.Text())
+
+	file := filepath.Join(t.TempDir(), "input.txt")
+	assert.NoError(t, os.WriteFile(file, []byte("from file"), 0o644))
+	o, err = exec.New("cat").WithStdinFile(file).Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "from file", o.Text())
+
+	_, err = exec.New("cat").WithStdinFile(file + ".missing").Output()
+	assert.Error(t, err)
+
+	// the file is read again on every run.
+	c := exec.New("cat").WithStdinFile(file)
+	for i := 0; i < 2; i++ {
+		o, err = c.Output()
+		assert.NoError(t, err)
+		assert.Equal(t, "from file", o.Text())
+	}
+
+	fake := exec.NewFakeRunner()
+	fake.Expect("cat").Times(2)
+	c = exec.New("cat").WithStdinFile(file).WithRunner(fake)
+	for i := 0; i < 2; i++ {
+		_, err = c.Output()
+		assert.NoError(t, err)
+	}
+
+	calls := fake.Calls()
+	if assert.Len(t, calls, 2) {
+		assert.Equal(t, "from file", string(calls[1].Stdin))
+	}
+}
+
+func TestInteract(t *testing.T) {
+	_, ok := exec.Which("sh")
+	if !ok {
+		t.Skip("sh not found")
+	}
+
+	i, err := exec.New("sh", "-c", `printf 'Continue? [y/N] '; read answer; echo "answer: $answer"; printf 'Name: ' >&2; read name; echo "hello $name"`).Interact()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = i.Expect(`Continue\? \[y/N\]`)
+	assert.NoError(t, err)
+	assert.NoError(t, i.SendLine("y"))
+
+	m, err := i.Expect(`answer: (\w+)`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"answer: y", "y"}, m)
+
+	assert.NoError(t, i.ExpectString("Name:"))
+	assert.NoError(t, i.SendLine("bob"))
+
+	_, err = i.ExpectTimeout("never printed", 100*time.Millisecond)
+	assert.Error(t, err)
+
+	res, err := i.Wait()
+	assert.NoError(t, err)
+	assert.Equal(t, "Continue? [y/N] answer: y\nName: hello bob\n", string(res.Stdout))
+	assert.Equal(t, res.Stdout, []byte(i.Output()))
+}
+
+func TestInteractTimeout(t *testing.T) {
+	_, ok := exec.Which("sleep")
+	if !ok {
+		t.Skip("sleep not found")
+	}
+
+	i, err := exec.New("sleep", "5").Interact()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = i.WithTimeout(50 * time.Millisecond).Expect("ready")
+	assert.True(t, errors.Is(err, exec.ErrExpectTimeout))
+	i.Stop()
+	_, err = i.Wait()
+	assert.Error(t, err)
+}
+
+type maskFunc func(s string) string
+
+func (f maskFunc) Mask(s string) string {
+	return f(s)
+}
+
+func TestInteractMasked(t *testing.T) {
+	_, ok := exec.Which("sh")
+	if !ok {
+		t.Skip("sh not found")
+	}
+
+	masker := maskFunc(func(s string) string {
+		return strings.ReplaceAll(s, "hunter2", "****")
+	})
+
+	i, err := exec.New("sh", "-c", `printf 'Password: ' >&2; read pw; echo "got $pw"; printf 'Again: '; read pw`).
+		WithMasker(masker).
+		Interact()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	i.WithTimeout(5 * time.Second)
+	assert.NoError(t, i.ExpectString("Password: "))
+	assert.NoError(t, i.SendLine("hunter2"))
+	assert.NoError(t, i.ExpectString("got ****"))
+	assert.NoError(t, i.ExpectString("Again: "))
+	assert.NoError(t, i.SendLine("x"))
+
+	res, err := i.Wait()
+	assert.NoError(t, err)
+	assert.Equal(t, "Password: got ****\nAgain: ", string(res.Stdout))
+}
+
+func TestInteractRestoresStdio(t *testing.T) {
+	_, ok := exec.Which("sh")
+	if !ok {
+		t.Skip("sh not found")
+	}
+
+	masker := maskFunc(func(s string) string {
+		return strings.ReplaceAll(s, "hunter2", "****")
+	})
+
+	c := exec.New("sh", "-c", "echo out hunter2; echo err >&2").WithMasker(masker)
+	i, err := c.Interact()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	res, err := i.Wait()
+	assert.NoError(t, err)
+	assert.Contains(t, string(res.Stdout), "out ****\n")
+
+	// a later run keeps stdout and stderr apart.
+	o, err := c.Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "out ****\n", o.Text())
+	assert.Equal(t, "err\n", o.ErrorText())
+}
+
+func TestInteractOutputLimit(t *testing.T) {
+	_, ok := exec.Which("sh")
+	if !ok {
+		t.Skip("sh not found")
+	}
+
+	i, err := exec.New("sh", "-c", `i=0; while [ $i -lt 2000 ]; do echo "line $i of chatty output"; i=$((i+1)); done; echo done`).
+		WithOutputLimit(&exec.OutputLimit{Head: 16, Tail: 16}).
+		Interact()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NoError(t, i.WithTimeout(10*time.Second).ExpectString("line 1999"))
+	res, err := i.Wait()
+	assert.NoError(t, err)
+	assert.True(t, res.StdoutTruncated)
+	assert.Len(t, res.Stdout, 32)
+	assert.True(t, strings.HasSuffix(string(res.Stdout), "done\n"))
+	assert.Greater(t, res.StdoutSize, int64(32))
+}
@@ -0,0 +1,275 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ArgMatcher matches a single command argument.
+type ArgMatcher func(arg string) bool
+
+// Exact matches an argument equal to value.
+func Exact(value string) ArgMatcher {
+	return func(arg string) bool {
+		return arg == value
+	}
+}
+
+// Regex matches an argument with the regular expression. It panics
+// if the pattern is invalid.
+func Regex(pattern string) ArgMatcher {
+	re := regexp.MustCompile(pattern)
+	return func(arg string) bool {
+		return re.MatchString(arg)
+	}
+}
+
+// AnyArg matches any single argument.
+func AnyArg() ArgMatcher {
+	return func(arg string) bool {
+		return true
+	}
+}
+
+// Expectation is a command expected by a FakeRunner and the result
+// returned when it is run.
+type Expectation struct {
+	name    string
+	args    []ArgMatcher
+	anyArgs bool
+	stdout  []byte
+	stderr  []byte
+	code    int
+	delay   time.Duration
+	err     error
+	times   int
+	calls   int
+}
+
+// WithArgs sets the matchers for the arguments after the command
+// name. The number of arguments must match the number of matchers.
+func (e *Expectation) WithArgs(matchers ...ArgMatcher) *Expectation {
+	e.args = matchers
+	e.anyArgs = false
+	return e
+}
+
+// WithAnyArgs matches the command regardless of its arguments.
+func (e *Expectation) WithAnyArgs() *Expectation {
+	e.anyArgs = true
+	return e
+}
+
+func (e *Expectation) Stdout(s string) *Expectation {
+	e.stdout = []byte(s)
+	return e
+}
+
+func (e *Expectation) Stderr(s string) *Expectation {
+	e.stderr = []byte(s)
+	return e
+}
+
+func (e *Expectation) ExitCode(code int) *Expectation {
+	e.code = code
+	return e
+}
+
+// Delay makes the command take the given time to complete.
+func (e *Expectation) Delay(d time.Duration) *Expectation {
+	e.delay = d
+	return e
+}
+
+// Fail makes the command fail to start with the error.
+func (e *Expectation) Fail(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+// Times sets how many times the command is expected to run. Use 0
+// to allow any number of runs. Defaults to 1.
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+func (e *Expectation) matches(name string, args []string) bool {
+	if e.times > 0 && e.calls >= e.times {
+		return false
+	}
+
+	if !strings.EqualFold(e.name, name) {
+		return false
+	}
+
+	if e.anyArgs {
+		return true
+	}
+
+	if len(args) != len(e.args) {
+		return false
+	}
+
+	for i, m := range e.args {
+		if !m(args[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// FakeCall records a command run through a FakeRunner.
+type FakeCall struct {
+	Name  string
+	Args  []string
+	Dir   string
+	Env   []string
+	Stdin []byte
+}
+
+// FakeRunner is a Runner for tests that returns canned results for
+// expected commands instead of spawning processes. It is safe for
+// concurrent use.
+//
+//	fake := exec.NewFakeRunner()
+//	fake.Expect("git", "rev-parse", "HEAD").Stdout("abc123\n")
+//	exec.SetRunner(fake)
+//	defer exec.SetRunner(nil)
+//	...
+//	err := fake.Verify()
+type FakeRunner struct {
+	mu           sync.Mutex
+	expectations []*Expectation
+	calls        []FakeCall
+	unexpected   []string
+}
+
+func NewFakeRunner() *FakeRunner {
+	return &FakeRunner{}
+}
+
+// Expect registers a command with the exact arguments.
+func (f *FakeRunner) Expect(name string, args ...string) *Expectation {
+	matchers := make([]ArgMatcher, len(args))
+	for i, arg := range args {
+		matchers[i] = Exact(arg)
+	}
+
+	return f.ExpectMatch(name, matchers...)
+}
+
+// ExpectMatch registers a command whose arguments are matched with
+// the matchers.
+func (f *FakeRunner) ExpectMatch(name string, matchers ...ArgMatcher) *Expectation {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	e := &Expectation{name: name, args: matchers, times: 1}
+	f.expectations = append(f.expectations, e)
+	return e
+}
+
+// Calls returns the commands that were run, in order.
+func (f *FakeRunner) Calls() []FakeCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	set := make([]FakeCall, len(f.calls))
+	copy(set, f.calls)
+	return set
+}
+
+// Verify returns an error when an expected command was not run the
+// expected number of times or an unexpected command was run.
+func (f *FakeRunner) Verify() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	problems := make([]string, 0)
+	for _, e := range f.expectations {
+		if e.times > 0 && e.calls != e.times {
+			problems = append(problems, fmt.Sprintf("expected %s to run %d time(s), ran %d time(s)", e.name, e.times, e.calls))
+		}
+	}
+
+	for _, cmd := range f.unexpected {
+		problems = append(problems, "unexpected command: "+cmd)
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return errors.New(strings.Join(problems, "\n"))
+}
+
+func (f *FakeRunner) RunCmd(c *Cmd) (*Result, error) {
+	name := c.Cmd.Path
+	if len(c.Cmd.Args) > 0 {
+		name = c.Cmd.Args[0]
+	}
+
+	name = filepath.Base(name)
+	if ext := filepath.Ext(name); strings.EqualFold(ext, ".exe") {
+		name = name[:len(name)-len(ext)]
+	}
+
+	args := []string{}
+	if len(c.Cmd.Args) > 1 {
+		args = c.Cmd.Args[1:]
+	}
+
+	call := FakeCall{Name: name, Args: args, Dir: c.Cmd.Dir, Env: c.Cmd.Env}
+	if c.Cmd.Stdin != nil && c.Cmd.Stdin != os.Stdin {
+		call.Stdin, _ = io.ReadAll(c.Cmd.Stdin)
+	}
+
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	var match *Expectation
+	for _, e := range f.expectations {
+		if e.matches(name, args) {
+			match = e
+			e.calls++
+			break
+		}
+	}
+
+	if match == nil {
+		f.unexpected = append(f.unexpected, strings.TrimSpace(name+" "+strings.Join(args, " ")))
+	}
+	f.mu.Unlock()
+
+	out := &Result{FileName: c.Cmd.Path, Args: c.Cmd.Args, StartedAt: time.Now().UTC()}
+	if match == nil {
+		out.Code = -1
+		out.EndedAt = out.StartedAt
+		return out, &StartError{FileName: c.Cmd.Path, Err: fmt.Errorf("unexpected command: %s", name)}
+	}
+
+	if match.err != nil {
+		out.Code = -1
+		out.EndedAt = out.StartedAt
+		return out, newStartError(c.Cmd.Path, match.err)
+	}
+
+	if match.delay > 0 {
+		c.sleep(match.delay)
+	}
+
+	out.Stdout = match.stdout
+	out.Stderr = match.stderr
+	out.Code = match.code
+	out.EndedAt = time.Now().UTC()
+	if match.code != 0 {
+		return out, &ExitError{FileName: c.Cmd.Path, Code: match.code}
+	}
+
+	return out, nil
+}
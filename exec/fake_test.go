@@ -0,0 +1,89 @@
+package exec_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hyprxlabs/go/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeRunner(t *testing.T) {
+	fake := exec.NewFakeRunner()
+	fake.Expect("git", "rev-parse", "HEAD").Stdout("abc123\n")
+	fake.ExpectMatch("git", exec.Exact("fetch"), exec.Regex("^orig")).ExitCode(128).Stderr("fatal\n")
+
+	exec.SetRunner(fake)
+	defer exec.SetRunner(nil)
+
+	o, err := exec.Output("git rev-parse HEAD")
+	assert.NoError(t, err)
+	assert.Equal(t, "abc123", o.Lines()[0])
+
+	o, err = exec.Command("git fetch origin").Output()
+	var ee *exec.ExitError
+	assert.True(t, errors.As(err, &ee))
+	assert.Equal(t, 128, o.Code)
+	assert.Equal(t, "fatal\n", o.ErrorText())
+
+	assert.NoError(t, fake.Verify())
+	assert.Len(t, fake.Calls(), 2)
+}
+
+func TestFakeRunnerVerify(t *testing.T) {
+	fake := exec.NewFakeRunner()
+	fake.Expect("make", "build")
+
+	_, err := exec.New("make", "test").WithRunner(fake).Output()
+	assert.Error(t, err)
+
+	err = fake.Verify()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "expected make to run 1 time(s), ran 0 time(s)")
+	assert.Contains(t, err.Error(), "unexpected command: make test")
+}
+
+func TestFakeRunnerPipeline(t *testing.T) {
+	fake := exec.NewFakeRunner()
+	fake.Expect("echo", "hello").Stdout("hello\n")
+	fake.Expect("tr", "a-z", "A-Z").Stdout("HELLO\n")
+
+	o, err := exec.New("echo", "hello").WithRunner(fake).Pipe(exec.New("tr", "a-z", "A-Z").WithRunner(fake)).Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "HELLO\n", o.Text())
+	assert.Len(t, o.Stages, 2)
+	assert.Equal(t, "hello\n", string(fake.Calls()[1].Stdin))
+}
+
+func TestFakeRunnerPipelineStageRunner(t *testing.T) {
+	_, ok := exec.Which("tr")
+	if !ok {
+		t.Skip("tr not found")
+	}
+
+	fake := exec.NewFakeRunner()
+	fake.Expect("echo", "hello").Stdout("hello\n")
+
+	// only the first stage is faked, tr runs as a process.
+	o, err := exec.New("echo", "hello").WithRunner(fake).Pipe(exec.New("tr", "a-z", "A-Z")).Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "HELLO\n", o.Text())
+	assert.Len(t, fake.Calls(), 1)
+	assert.NoError(t, fake.Verify())
+}
+
+func TestFakeRunnerStart(t *testing.T) {
+	fake := exec.NewFakeRunner()
+	c := exec.New("sleep", "10").WithRunner(fake)
+
+	err := c.Start()
+	assert.True(t, errors.Is(err, exec.ErrRunnerStart))
+	assert.Zero(t, c.Pid())
+
+	_, err = exec.New("sh").WithRunner(fake).Interact()
+	assert.True(t, errors.Is(err, exec.ErrRunnerStart))
+
+	err = exec.NewSupervisor().Add("db", exec.New("sleep", "10").WithRunner(fake), nil).Start()
+	assert.True(t, errors.Is(err, exec.ErrRunnerStart))
+	assert.Empty(t, fake.Calls())
+}
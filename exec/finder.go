@@ -2,7 +2,12 @@ package exec
 
 import (
 	"errors"
+	"fmt"
+	ose "os/exec"
+	"regexp"
 	"runtime"
+	"strings"
+	"sync"
 	"unicode"
 
 	"github.com/hyprxlabs/go/env"
@@ -15,16 +20,74 @@ type Executable struct {
 	Windows  []string
 	Linux    []string
 	Darwin   []string
+	// VersionArgs are the arguments that make the executable print its
+	// version. Defaults to --version.
+	VersionArgs []string
+	// VersionPattern is a regular expression that extracts the version
+	// from the probe output. The first capture group is used if present.
+	VersionPattern string
+	// Constraint limits Find to candidates whose version satisfies it,
+	// e.g. ">=18". Every match of a candidate on PATH is probed. See
+	// VersionConstraint.
+	Constraint string
 }
 
+// ExecutableRegistry resolves executables by name. It is safe for
+// concurrent use.
 type ExecutableRegistry struct {
-	data map[string]Executable
+	mu       sync.RWMutex
+	data     map[string]Executable
+	found    map[string]string
+	versions map[string]*Version
+	path     string // PATH the found paths were resolved with
+	stats    CacheStats
 }
 
-var Registry = &ExecutableRegistry{data: make(map[string]Executable)}
+// RejectedCandidate is a candidate path whose version did not satisfy
+// a constraint.
+type RejectedCandidate struct {
+	Path    string
+	Version *Version
+	Err     error
+}
+
+// VersionError is returned by Find when executables were found but
+// none satisfied the version constraint.
+type VersionError struct {
+	Name       string
+	Constraint string
+	Rejected   []RejectedCandidate
+}
+
+func (e *VersionError) Error() string {
+	parts := make([]string, len(e.Rejected))
+	for i, r := range e.Rejected {
+		if r.Err != nil {
+			parts[i] = fmt.Sprintf("%s (%v)", r.Path, r.Err)
+			continue
+		}
+
+		parts[i] = fmt.Sprintf("%s (%s)", r.Path, r.Version)
+	}
+
+	return fmt.Sprintf("no %s satisfies version %s, rejected: %s", e.Name, e.Constraint, strings.Join(parts, ", "))
+}
+
+var Registry = NewExecutableRegistry()
+
+func NewExecutableRegistry() *ExecutableRegistry {
+	return &ExecutableRegistry{
+		data:     make(map[string]Executable),
+		found:    make(map[string]string),
+		versions: make(map[string]*Version),
+	}
+}
 
 func (r *ExecutableRegistry) Register(name string, exe *Executable) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.data[name] = *exe
+	delete(r.found, name)
 
 	if exe.Variable == "" {
 		sb := underscore([]rune(name), &underscoreOptions{Screaming: true})
@@ -33,20 +96,67 @@ func (r *ExecutableRegistry) Register(name string, exe *Executable) {
 }
 
 func (r *ExecutableRegistry) Set(name string, exe *Executable) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.data[name] = *exe
+	delete(r.found, name)
 }
 
 func (r *ExecutableRegistry) Get(name string) (*Executable, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	item, ok := r.data[name]
 	return &item, ok
 }
 
 func (r *ExecutableRegistry) Has(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	_, ok := r.data[name]
 	return ok
 }
 
-func (r *ExecutableRegistry) Find(name string, options *WhichOptions) (string, error) {
+// Invalidate forgets the resolved paths and detected versions of the
+// named executables, or of all executables when no names are given.
+func (r *ExecutableRegistry) Invalidate(names ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.invalidate(names...)
+}
+
+func (r *ExecutableRegistry) invalidate(names ...string) {
+	if len(names) == 0 {
+		r.found = make(map[string]string)
+		r.versions = make(map[string]*Version)
+		r.stats.Invalidations++
+		return
+	}
+
+	for _, name := range names {
+		if path, ok := r.found[name]; ok {
+			delete(r.versions, path)
+			delete(r.found, name)
+		}
+	}
+
+	r.stats.Invalidations++
+}
+
+// Stats returns the statistics of the resolved path cache.
+func (r *ExecutableRegistry) Stats() CacheStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	stats := r.stats
+	stats.Entries = len(r.found)
+	return stats
+}
+
+// lookup returns the executable registered for the name, registering
+// a default one on first use, and the cached path if there is one.
+// The cache is dropped when PATH changed since it was filled.
+func (r *ExecutableRegistry) lookup(name string, useCache bool) (Executable, string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	m, ok := r.data[name]
 	if !ok {
 		sb := underscore([]rune(name), &underscoreOptions{Screaming: true})
@@ -55,79 +165,173 @@ func (r *ExecutableRegistry) Find(name string, options *WhichOptions) (string, e
 		r.data[name] = m
 	}
 
+	if path := env.GetPath(); path != r.path {
+		if len(r.found) > 0 {
+			r.invalidate()
+		}
+		r.path = path
+	}
+
+	if !useCache {
+		return m, ""
+	}
+
+	if found, ok := r.found[name]; ok {
+		r.stats.Hits++
+		return m, found
+	}
+
+	r.stats.Misses++
+	if m.Constraint != "" {
+		// a pinned path must satisfy the constraint like any other
+		// candidate, see Find.
+		return m, ""
+	}
+
+	return m, m.Path
+}
+
+func (r *ExecutableRegistry) Find(name string, options *WhichOptions) (string, error) {
 	if options == nil {
 		options = &WhichOptions{}
 	}
 
-	if options.UseCache && m.Path != "" {
-		return m.Path, nil
+	m, cached := r.lookup(name, options.UseCache)
+	if cached != "" {
+		return cached, nil
 	}
 
-	if m.Variable != "" {
-		value := env.Get(m.Variable)
-		if value != "" {
-			value, _ = env.Expand(value)
-			if value != "" {
-				next, ok := WhichFirst(value, options)
-				if ok {
-					m.Path = next
-					return m.Path, nil
-				}
-			}
+	var vc *VersionConstraint
+	if m.Constraint != "" {
+		next, err := ParseConstraint(m.Constraint)
+		if err != nil {
+			return "", err
 		}
-	}
 
-	if m.Path != "" {
-		next, ok := WhichFirst(m.Path, options)
-		if ok {
-			m.Path = next
-			return m.Path, nil
-		}
+		vc = next
 	}
 
-	if runtime.GOOS == "windows" {
-		for _, path := range m.Windows {
-			if emptySpace(path) {
+	var rejected []RejectedCandidate
+	for _, candidate := range m.candidates() {
+		if vc == nil {
+			next, ok := WhichFirst(candidate, options)
+			if !ok {
 				continue
 			}
 
-			exe2, _ := env.Expand(path)
-			if exe2 == "" {
+			r.remember(name, next)
+			return next, nil
+		}
+
+		// a later match on PATH may satisfy the constraint when the
+		// first one does not.
+		for _, match := range WhichAll(candidate, options) {
+			v, err := r.probe(match.Path, &m)
+			if err != nil || !vc.Check(v) {
+				rejected = append(rejected, RejectedCandidate{Path: match.Path, Version: v, Err: err})
 				continue
 			}
 
-			next, ok := WhichFirst(exe2, options)
-			if ok {
-				m.Path = next
-				return m.Path, nil
-			}
+			r.remember(name, match.Path)
+			return match.Path, nil
 		}
+	}
 
-		return "", errors.New("executable not found: " + name)
+	if len(rejected) > 0 {
+		return "", &VersionError{Name: name, Constraint: m.Constraint, Rejected: rejected}
 	}
 
-	if runtime.GOOS == "darwin" {
-		for _, path := range m.Darwin {
-			if emptySpace(path) {
-				continue
-			}
+	return "", errors.New("executable not found: " + name)
+}
 
-			exe2, _ := env.Expand(path)
-			if exe2 == "" {
-				continue
-			}
+func (r *ExecutableRegistry) remember(name, path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.found[name] = path
+}
+
+// Version finds the executable and returns its detected version.
+// Versions are cached by path.
+func (r *ExecutableRegistry) Version(name string, options *WhichOptions) (*Version, error) {
+	path, err := r.Find(name, options)
+	if err != nil {
+		return nil, err
+	}
+
+	m, _ := r.Get(name)
+	return r.probe(path, m)
+}
+
+// probe runs the executable's version command and parses its output.
+func (r *ExecutableRegistry) probe(path string, m *Executable) (*Version, error) {
+	r.mu.RLock()
+	v, ok := r.versions[path]
+	r.mu.RUnlock()
+	if ok {
+		return v, nil
+	}
+
+	var pattern *regexp.Regexp
+	if m.VersionPattern != "" {
+		next, err := regexp.Compile(m.VersionPattern)
+		if err != nil {
+			return nil, err
+		}
 
-			next, ok := WhichFirst(exe2, options)
-			if ok {
-				m.Path = next
-				return m.Path, nil
+		pattern = next
+	}
+
+	args := m.VersionArgs
+	if len(args) == 0 {
+		args = []string{"--version"}
+	}
+
+	// the exit code is ignored as some tools exit non-zero after
+	// printing their version.
+	out, err := ose.Command(path, args...).CombinedOutput()
+	if len(out) == 0 && err != nil {
+		return nil, err
+	}
+
+	v, err = extractVersion(string(out), pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.versions[path] = v
+	r.mu.Unlock()
+	return v, nil
+}
+
+// candidates returns the paths to search in order: the environment
+// variable override, the last known path and the paths for the
+// current OS.
+func (m *Executable) candidates() []string {
+	set := make([]string, 0)
+	if m.Variable != "" {
+		value := env.Get(m.Variable)
+		if value != "" {
+			value, _ = env.Expand(value)
+			if value != "" {
+				set = append(set, value)
 			}
 		}
+	}
 
+	if m.Path != "" {
+		set = append(set, m.Path)
+	}
+
+	paths := m.Linux
+	if runtime.GOOS == "windows" {
+		paths = m.Windows
+	} else if runtime.GOOS == "darwin" {
 		// fallthrough to unix
+		paths = append(append([]string{}, m.Darwin...), m.Linux...)
 	}
 
-	for _, path := range m.Linux {
+	for _, path := range paths {
 		if emptySpace(path) {
 			continue
 		}
@@ -137,14 +341,10 @@ func (r *ExecutableRegistry) Find(name string, options *WhichOptions) (string, e
 			continue
 		}
 
-		next, ok := WhichFirst(exe2, options)
-		if ok {
-			m.Path = next
-			return m.Path, nil
-		}
+		set = append(set, exe2)
 	}
 
-	return "", errors.New("executable not found: " + name)
+	return set
 }
 
 func Register(name string, exe *Executable) {
@@ -0,0 +1,228 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Group runs a set of independent commands in parallel with an
+// optional limit on how many run at the same time. Each command is
+// started in its own process group so cancellation stops the
+// processes it spawns as well.
+type Group struct {
+	items    []groupItem
+	limit    int
+	failFast bool
+	ctx      context.Context
+	stdout   io.Writer
+	stderr   io.Writer
+}
+
+type groupItem struct {
+	label string
+	cmd   *Cmd
+}
+
+// GroupError is returned when one or more commands in a group fail.
+// Errors are in the order the commands were added to the group.
+type GroupError struct {
+	Labels []string
+	Errors []error
+}
+
+func (e *GroupError) Error() string {
+	msg := "Group execution failed with errors: "
+	for i, err := range e.Errors {
+		msg += e.Labels[i] + ": " + err.Error() + ";\n"
+	}
+
+	return msg
+}
+
+// Unwrap returns the first error.
+func (e *GroupError) Unwrap() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+
+	return e.Errors[0]
+}
+
+// NewGroup creates a group that runs at most limit commands at the
+// same time. A limit less than 1 runs all commands at once.
+func NewGroup(limit int) *Group {
+	return &Group{limit: limit, stdout: os.Stdout, stderr: os.Stderr}
+}
+
+// Parallel runs the commands with at most limit running at the same
+// time and captures their output. Commands are labeled by index.
+func Parallel(limit int, cmds ...*Cmd) ([]*Result, error) {
+	g := NewGroup(limit)
+	for i, c := range cmds {
+		g.Add(fmt.Sprintf("%d", i), c)
+	}
+
+	return g.Output()
+}
+
+// Add adds a command to the group. The label prefixes streamed
+// output and error messages.
+func (g *Group) Add(label string, cmd *Cmd) *Group {
+	g.items = append(g.items, groupItem{label: label, cmd: cmd})
+	return g
+}
+
+// WithFailFast stops all running commands and skips pending commands
+// as soon as one command fails. Otherwise all commands are run.
+func (g *Group) WithFailFast(enabled bool) *Group {
+	g.failFast = enabled
+	return g
+}
+
+// WithContext sets a context that stops all running commands and
+// skips pending ones when it is done.
+func (g *Group) WithContext(ctx context.Context) *Group {
+	g.ctx = ctx
+	return g
+}
+
+// WithOutput sets the writers used by Run for the prefixed output.
+// Defaults to os.Stdout and os.Stderr.
+func (g *Group) WithOutput(stdout, stderr io.Writer) *Group {
+	g.stdout = stdout
+	g.stderr = stderr
+	return g
+}
+
+// Run runs the commands, streaming their output line by line prefixed
+// with "[label] " while also capturing it into the results.
+func (g *Group) Run() ([]*Result, error) {
+	return g.exec(true)
+}
+
+// Output runs the commands and captures their output.
+func (g *Group) Output() ([]*Result, error) {
+	return g.exec(false)
+}
+
+func (g *Group) exec(stream bool) ([]*Result, error) {
+	n := len(g.items)
+	results := make([]*Result, n)
+	errs := make([]error, n)
+	limit := g.limit
+	if limit <= 0 || limit > n {
+		limit = n
+	}
+
+	parent := g.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, limit)
+	for i, item := range g.items {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+
+		if ctx.Err() != nil {
+			results[i] = notStarted(item.cmd)
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, item groupItem) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			// the settings only apply to this run so the group can be
+			// run again.
+			c := item.cmd
+			processGroup, onStdout, onStderr := c.processGroup, c.onStdoutLine, c.onStderrLine
+			defer func() {
+				c.processGroup, c.onStdoutLine, c.onStderrLine = processGroup, onStdout, onStderr
+			}()
+
+			// the whole tree must be stopped when the group is cancelled.
+			c.WithProcessGroup(true)
+			if stream {
+				c.OnStdoutLine(prefixLines(&mu, g.stdout, item.label, onStdout))
+				c.OnStderrLine(prefixLines(&mu, g.stderr, item.label, onStderr))
+			}
+
+			// a cancellation before exec starts must still stop the run.
+			c.begin()
+			finished := make(chan struct{})
+			go func() {
+				select {
+				case <-ctx.Done():
+					item.cmd.Stop()
+				case <-finished:
+				}
+			}()
+
+			results[i], errs[i] = item.cmd.exec(modeCapture)
+			close(finished)
+			if errs[i] != nil && g.failFast {
+				cancel()
+			}
+		}(i, item)
+	}
+
+	wg.Wait()
+
+	ge := &GroupError{}
+	for i, err := range errs {
+		if err != nil {
+			ge.Labels = append(ge.Labels, g.items[i].label)
+			ge.Errors = append(ge.Errors, err)
+		}
+	}
+
+	if len(ge.Errors) > 0 {
+		return results, ge
+	}
+
+	if parent.Err() != nil {
+		return results, parent.Err()
+	}
+
+	return results, nil
+}
+
+func notStarted(c *Cmd) *Result {
+	now := time.Now().UTC()
+	return &Result{
+		FileName:  c.Path,
+		Args:      c.Args,
+		Code:      -1,
+		Stdout:    make([]byte, 0),
+		Stderr:    make([]byte, 0),
+		StartedAt: now,
+		EndedAt:   now,
+	}
+}
+
+func prefixLines(mu *sync.Mutex, w io.Writer, label string, next func(line string)) func(line string) {
+	return func(line string) {
+		if w != nil {
+			mu.Lock()
+			_, _ = fmt.Fprintf(w, "[%s] %s\n", label, line)
+			mu.Unlock()
+		}
+
+		if next != nil {
+			next(line)
+		}
+	}
+}
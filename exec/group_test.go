@@ -0,0 +1,113 @@
+package exec_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyprxlabs/go/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupRunPrefixesOutput(t *testing.T) {
+	_, ok := exec.Which("sh")
+	if !ok {
+		t.Skip("sh not found")
+	}
+
+	var stdout bytes.Buffer
+	results, err := exec.NewGroup(2).
+		Add("a", exec.New("sh", "-c", "echo one")).
+		Add("b", exec.New("sh", "-c", "echo two")).
+		Add("c", exec.New("sh", "-c", "echo three")).
+		WithOutput(&stdout, nil).
+		Run()
+
+	assert.NoError(t, err)
+	assert.Len(t, results, 3)
+	assert.Equal(t, "one\n", results[0].Text())
+	assert.Equal(t, "three\n", results[2].Text())
+	assert.Contains(t, stdout.String(), "[b] two\n")
+}
+
+func TestGroupFailFast(t *testing.T) {
+	_, ok := exec.Which("sh")
+	if !ok {
+		t.Skip("sh not found")
+	}
+
+	start := time.Now()
+	results, err := exec.NewGroup(2).
+		Add("slow", exec.New("sh", "-c", "sleep 10")).
+		Add("fail", exec.New("sh", "-c", "exit 1")).
+		Add("pending", exec.New("sh", "-c", "sleep 10")).
+		WithFailFast(true).
+		Output()
+
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Equal(t, 1, results[1].Code)
+	assert.NotEqual(t, 0, results[0].Code)
+	assert.Equal(t, -1, results[2].Code)
+	assert.True(t, strings.Contains(err.Error(), "fail:"))
+}
+
+func TestGroupRunTwice(t *testing.T) {
+	_, ok := exec.Which("sh")
+	if !ok {
+		t.Skip("sh not found")
+	}
+
+	var stdout bytes.Buffer
+	var lines []string
+	c := exec.New("sh", "-c", "echo one").OnStdoutLine(func(line string) {
+		lines = append(lines, line)
+	})
+	g := exec.NewGroup(1).Add("a", c).WithOutput(&stdout, nil)
+
+	for i := 0; i < 2; i++ {
+		_, err := g.Run()
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, "[a] one\n[a] one\n", stdout.String())
+	assert.Equal(t, []string{"one", "one"}, lines)
+
+	// the command is left as it was configured.
+	o, err := c.Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "one\n", o.Text())
+	assert.Equal(t, "[a] one\n[a] one\n", stdout.String())
+}
+
+func TestStopAfterExit(t *testing.T) {
+	_, ok := exec.Which("sh")
+	if !ok {
+		t.Skip("sh not found")
+	}
+
+	c := exec.New("sh", "-c", "echo one")
+	_, err := c.Output()
+	assert.NoError(t, err)
+
+	c.Stop()
+	o, err := c.Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "one\n", o.Text())
+}
+
+func TestStopBeforeOutput(t *testing.T) {
+	_, ok := exec.Which("sleep")
+	if !ok {
+		t.Skip("sleep not found")
+	}
+
+	c := exec.New("sleep", "2").WithGracePeriod(100 * time.Millisecond)
+	c.Stop()
+	start := time.Now()
+	o, err := c.Output()
+	assert.Error(t, err)
+	assert.NotEqual(t, 0, o.Code)
+	assert.Less(t, time.Since(start), time.Second)
+}
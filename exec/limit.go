@@ -0,0 +1,209 @@
+package exec
+
+import "os"
+
+// OutputLimit caps how much captured output is kept in memory. The
+// first Head and the last Tail bytes of each stream are kept; the
+// output in between is dropped and the result is marked truncated.
+//
+//	exec.New("build").WithOutputLimit(&exec.OutputLimit{Head: 64 << 10, Tail: 64 << 10})
+type OutputLimit struct {
+	// Head is the number of bytes kept from the start of the output.
+	Head int
+	// Tail is the number of bytes kept from the end of the output.
+	Tail int
+	// Spill writes the full output of each stream to a temporary file,
+	// see Result.StdoutFile. The caller removes the files.
+	Spill bool
+	// SpillDir is the directory for the spill files, the default is
+	// os.TempDir.
+	SpillDir string
+}
+
+// WithOutputLimit limits the output Output, RunAndCapture and
+// Interact keep in the Result. Without a limit the output is kept in full. Line
+// callbacks and other writers still receive all output. In a pipeline
+// the limit of the last stage applies to the captured stdout and the
+// limit of each stage to its stderr.
+func (c *Cmd) WithOutputLimit(limit *OutputLimit) *Cmd {
+	c.outputLimit = limit
+	return c
+}
+
+// capture collects the output of a stream for the Result, keeping at
+// most the head and tail allowed by the limit.
+type capture struct {
+	limit *OutputLimit
+	head  []byte
+	tail  []byte
+	total int64
+	file  *os.File
+	err   error
+}
+
+// captureLimit returns the output limit that applies in the mode.
+func (c *Cmd) captureLimit(mode runMode) *OutputLimit {
+	if !mode.captures() {
+		return nil
+	}
+
+	return c.outputLimit
+}
+
+// newCaptures returns the stdout and stderr captures for the mode.
+func (c *Cmd) newCaptures(mode runMode) (*capture, *capture, error) {
+	limit := c.captureLimit(mode)
+	stdout, err := newCapture(limit, "stdout")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stderr, err := newCapture(limit, "stderr")
+	if err != nil {
+		stdout.discard()
+		return nil, nil, err
+	}
+
+	return stdout, stderr, nil
+}
+
+// newPipelineCaptures returns the stdout capture of the last stage,
+// limited by its output limit, and the stderr capture of each stage,
+// limited by the stage's output limit.
+func newPipelineCaptures(cmds []*Cmd, mode runMode) (*capture, []*capture, error) {
+	stdout, err := newCapture(cmds[len(cmds)-1].captureLimit(mode), "stdout")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stderr := make([]*capture, len(cmds))
+	for i, cmd := range cmds {
+		if stderr[i], err = newCapture(cmd.captureLimit(mode), "stderr"); err != nil {
+			stdout.discard()
+			for _, b := range stderr[:i] {
+				b.discard()
+			}
+
+			return nil, nil, err
+		}
+	}
+
+	return stdout, stderr, nil
+}
+
+func newCapture(limit *OutputLimit, name string) (*capture, error) {
+	b := &capture{limit: limit, head: make([]byte, 0)}
+	if limit != nil && limit.Spill {
+		f, err := os.CreateTemp(limit.SpillDir, "exec-"+name+"-*.log")
+		if err != nil {
+			return nil, err
+		}
+
+		b.file = f
+	}
+
+	return b, nil
+}
+
+func (b *capture) Write(p []byte) (int, error) {
+	size := len(p)
+	b.total += int64(size)
+	if b.file != nil && b.err == nil {
+		_, b.err = b.file.Write(p)
+	}
+
+	if b.limit == nil {
+		b.head = append(b.head, p...)
+		return size, nil
+	}
+
+	if n := b.limit.Head - len(b.head); n > 0 {
+		if n > len(p) {
+			n = len(p)
+		}
+
+		b.head = append(b.head, p[:n]...)
+		p = p[n:]
+	}
+
+	if len(p) == 0 || b.limit.Tail <= 0 {
+		return size, nil
+	}
+
+	b.tail = append(b.tail, p...)
+	// compact once the buffer holds twice the tail to keep appends cheap.
+	if len(b.tail) > 2*b.limit.Tail {
+		b.tail = append(b.tail[:0], b.tail[len(b.tail)-b.limit.Tail:]...)
+	}
+
+	return size, nil
+}
+
+// Bytes returns the kept output, the head followed by the tail.
+func (b *capture) Bytes() []byte {
+	tail := b.tail
+	if b.limit != nil && len(tail) > b.limit.Tail {
+		tail = tail[len(tail)-b.limit.Tail:]
+	}
+
+	if len(tail) == 0 {
+		return b.head
+	}
+
+	return append(append(make([]byte, 0, len(b.head)+len(tail)), b.head...), tail...)
+}
+
+// truncated reports whether output was dropped.
+func (b *capture) truncated() bool {
+	return b.total > int64(len(b.Bytes()))
+}
+
+// close closes the spill file and returns its path.
+func (b *capture) close() (string, error) {
+	if b.file == nil {
+		return "", nil
+	}
+
+	err := b.file.Close()
+	if b.err != nil {
+		err = b.err
+	}
+
+	return b.file.Name(), err
+}
+
+// discard closes and removes the spill file.
+func (b *capture) discard() {
+	if name, _ := b.close(); name != "" {
+		_ = os.Remove(name)
+	}
+}
+
+// fillOutput sets the captured stdout and stderr on the result.
+func fillOutput(out *Result, stdout, stderr *capture) error {
+	out.Stdout = stdout.Bytes()
+	out.Stderr = stderr.Bytes()
+	out.StdoutSize = stdout.total
+	out.StderrSize = stderr.total
+	out.StdoutTruncated = stdout.truncated()
+	out.StderrTruncated = stderr.truncated()
+
+	var err, err2 error
+	out.StdoutFile, err = stdout.close()
+	out.StderrFile, err2 = stderr.close()
+	if err == nil {
+		err = err2
+	}
+
+	return err
+}
+
+// removeSpillFiles removes the spill files of a result that is
+// discarded, e.g. a failed attempt that is retried.
+func removeSpillFiles(out *Result) {
+	for _, name := range []string{out.StdoutFile, out.StderrFile} {
+		if name != "" {
+			_ = os.Remove(name)
+		}
+	}
+}
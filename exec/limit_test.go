@@ -0,0 +1,108 @@
+package exec_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hyprxlabs/go/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputLimit(t *testing.T) {
+	_, ok := exec.Which("sh")
+	if !ok {
+		t.Skip("sh not found")
+	}
+
+	script := "for i in 1 2 3 4 5 6 7 8 9; do echo line$i; done; echo err >&2"
+	o, err := exec.New("sh", "-c", script).WithOutputLimit(&exec.OutputLimit{Head: 12, Tail: 12}).Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "line1\nline2\nline8\nline9\n", o.Text())
+	assert.True(t, o.StdoutTruncated)
+	assert.Equal(t, int64(54), o.StdoutSize)
+	assert.False(t, o.StderrTruncated)
+	assert.Equal(t, "err\n", string(o.Stderr))
+	assert.Empty(t, o.StdoutFile)
+
+	o, err = exec.New("sh", "-c", script).WithOutputLimit(&exec.OutputLimit{Head: 6}).Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "line1\n", o.Text())
+	assert.True(t, o.StdoutTruncated)
+}
+
+func TestOutputLimitSpill(t *testing.T) {
+	_, ok := exec.Which("sh")
+	if !ok {
+		t.Skip("sh not found")
+	}
+
+	dir := t.TempDir()
+	limit := &exec.OutputLimit{Tail: 5, Spill: true, SpillDir: dir}
+	o, err := exec.New("sh", "-c", "echo first; echo last").WithOutputLimit(limit).Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "last\n", o.Text())
+	assert.True(t, o.StdoutTruncated)
+
+	data, err := os.ReadFile(o.StdoutFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "first\nlast\n", string(data))
+	assert.True(t, strings.HasPrefix(o.StderrFile, dir))
+
+	_, err = exec.New("definitely-not-a-real-command-xyz").WithOutputLimit(limit).Output()
+	assert.Error(t, err)
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestOutputLimitRunner(t *testing.T) {
+	fake := exec.NewFakeRunner()
+	fake.Expect("git", "log").Stdout("0123456789")
+
+	o, err := exec.New("git", "log").WithRunner(fake).WithOutputLimit(&exec.OutputLimit{Head: 2, Tail: 2}).Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "0189", o.Text())
+	assert.True(t, o.StdoutTruncated)
+	assert.Equal(t, int64(10), o.StdoutSize)
+}
+
+func TestOutputLimitPipeline(t *testing.T) {
+	_, ok := exec.Which("sh")
+	if !ok {
+		t.Skip("sh not found")
+	}
+
+	_, ok = exec.Which("cat")
+	if !ok {
+		t.Skip("cat not found")
+	}
+
+	script := "for i in 1 2 3 4 5 6 7 8 9; do echo line$i; done; echo error >&2"
+	o, err := exec.New("sh", "-c", script).WithOutputLimit(&exec.OutputLimit{Head: 2}).
+		Pipe(exec.New("cat").WithOutputLimit(&exec.OutputLimit{Head: 6, Tail: 6})).
+		Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "line1\nline9\n", o.Text())
+	assert.True(t, o.StdoutTruncated)
+	assert.Equal(t, int64(54), o.StdoutSize)
+	assert.Equal(t, "er", string(o.Stages[0].Stderr))
+	assert.True(t, o.Stages[0].StderrTruncated)
+	assert.Equal(t, int64(6), o.Stages[0].StderrSize)
+}
+
+func TestOutputLimitPipelineRunner(t *testing.T) {
+	fake := exec.NewFakeRunner()
+	fake.Expect("gen").Stdout("0123456789").Stderr("warning")
+	fake.Expect("cat").Stdout("0123456789")
+
+	o, err := exec.New("gen").WithRunner(fake).WithOutputLimit(&exec.OutputLimit{Head: 4}).
+		Pipe(exec.New("cat").WithRunner(fake).WithOutputLimit(&exec.OutputLimit{Tail: 3})).
+		Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "789", o.Text())
+	assert.True(t, o.StdoutTruncated)
+	assert.Equal(t, "0123456789", string(fake.Calls()[1].Stdin))
+	assert.Equal(t, "warn", string(o.Stages[0].Stderr))
+	assert.True(t, o.Stages[0].StderrTruncated)
+}
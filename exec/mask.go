@@ -0,0 +1,167 @@
+package exec
+
+import (
+	"bytes"
+	"io"
+	ose "os/exec"
+	"strings"
+)
+
+// Masker replaces sensitive values in text before it is written
+// anywhere. *secrets.SecretMasker from github.com/hyprxlabs/go/secrets
+// satisfies this interface.
+type Masker interface {
+	Mask(input string) string
+}
+
+var (
+	masker Masker
+)
+
+// SetMasker sets the masker used by all commands that do not
+// have their own masker. Pass nil to disable masking.
+func SetMasker(m Masker) {
+	masker = m
+}
+
+// WithMasker sets the masker used to redact stdout, stderr and
+// the values passed to loggers for this command.
+func (c *Cmd) WithMasker(m Masker) *Cmd {
+	c.masker = m
+	return c
+}
+
+func (c *Cmd) getMasker() Masker {
+	if c.masker != nil {
+		return c.masker
+	}
+
+	return masker
+}
+
+// masked returns a copy of the command with the path, args and
+// environment masked so it can be safely handed to loggers.
+func (c *Cmd) masked() *Cmd {
+	m := c.getMasker()
+	if m == nil {
+		return c
+	}
+
+	mask := func(values []string) []string {
+		if values == nil {
+			return nil
+		}
+
+		set := make([]string, len(values))
+		for i, v := range values {
+			set[i] = m.Mask(v)
+		}
+		return set
+	}
+
+	inner := &ose.Cmd{
+		Path:   m.Mask(c.Cmd.Path),
+		Args:   mask(c.Cmd.Args),
+		Env:    mask(c.Cmd.Env),
+		Dir:    c.Cmd.Dir,
+		Stdin:  c.Cmd.Stdin,
+		Stdout: c.Cmd.Stdout,
+		Stderr: c.Cmd.Stderr,
+	}
+
+	return &Cmd{Cmd: inner, ctx: c.ctx, masker: c.masker}
+}
+
+// maskWriter buffers output until a newline is written so that
+// secrets split across multiple writes are still masked before
+// the output reaches the underlying writer.
+type maskWriter struct {
+	masker Masker
+	w      io.Writer
+	buf    []byte
+	max    int
+	// chunked masks each write as is instead of buffering lines.
+	chunked bool
+}
+
+func newMaskWriter(m Masker, w io.Writer, max int) *maskWriter {
+	if max <= 0 {
+		max = DefaultMaxLineLength
+	}
+
+	return &maskWriter{masker: m, w: w, max: max}
+}
+
+func (m *maskWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if m.chunked {
+		if err := m.write(p); err != nil {
+			return 0, err
+		}
+
+		return n, nil
+	}
+
+	m.buf = append(m.buf, p...)
+	i := bytes.LastIndexByte(m.buf, '\n')
+	if i < 0 {
+		if len(m.buf) < m.max {
+			return n, nil
+		}
+
+		// a line without a newline has grown too large, write all but
+		// a tail so memory stays bounded.
+		if err := m.writeHead(); err != nil {
+			return 0, err
+		}
+
+		return n, nil
+	}
+
+	err := m.write(m.buf[:i+1])
+	m.buf = append(m.buf[:0], m.buf[i+1:]...)
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// writeHead masks the buffer and writes all but its last half, which
+// stays buffered so a secret continuing in the next write is masked
+// too. The cut is moved forward while it splits a secret, which shows
+// as the masked buffer not ending with the masked tail.
+func (m *maskWriter) writeHead() error {
+	whole := m.masker.Mask(string(m.buf))
+	cut := len(m.buf) - m.max/2
+	for step := 1; cut < len(m.buf); step *= 2 {
+		tail := m.masker.Mask(string(m.buf[cut:]))
+		if strings.HasSuffix(whole, tail) {
+			_, err := io.WriteString(m.w, whole[:len(whole)-len(tail)])
+			m.buf = append(m.buf[:0], m.buf[cut:]...)
+			return err
+		}
+
+		cut += step
+	}
+
+	_, err := io.WriteString(m.w, whole)
+	m.buf = m.buf[:0]
+	return err
+}
+
+func (m *maskWriter) write(p []byte) error {
+	if len(p) == 0 {
+		return nil
+	}
+
+	_, err := io.WriteString(m.w, m.masker.Mask(string(p)))
+	return err
+}
+
+// Flush masks and writes any buffered output.
+func (m *maskWriter) Flush() error {
+	err := m.write(m.buf)
+	m.buf = m.buf[:0]
+	return err
+}
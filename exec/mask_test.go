@@ -0,0 +1,76 @@
+package exec
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type replaceMasker struct {
+	values []string
+}
+
+func (m *replaceMasker) Mask(input string) string {
+	for _, v := range m.values {
+		input = strings.ReplaceAll(input, v, "****")
+	}
+
+	return input
+}
+
+func TestMaskWriterSplitSecret(t *testing.T) {
+	var b bytes.Buffer
+	w := newMaskWriter(&replaceMasker{values: []string{"secret"}}, &b, 0)
+
+	_, _ = w.Write([]byte("the sec"))
+	_, _ = w.Write([]byte("ret is\nsafe sec"))
+	assert.Equal(t, "the **** is\n", b.String())
+
+	_, _ = w.Write([]byte("ret"))
+	assert.NoError(t, w.Flush())
+	assert.Equal(t, "the **** is\nsafe ****", b.String())
+}
+
+func TestMaskWriterLongLine(t *testing.T) {
+	var b bytes.Buffer
+	w := newMaskWriter(&replaceMasker{values: []string{"secret"}}, &b, 16)
+
+	// the secret starts at the end of a line that exceeds the maximum.
+	_, _ = w.Write([]byte("aaaaaaaaaaaaaase"))
+	_, _ = w.Write([]byte("cret bbb\n"))
+	assert.Equal(t, "aaaaaaaaaaaaaa**** bbb\n", b.String())
+
+	// the secret spans the point where the long line is cut.
+	b.Reset()
+	_, _ = w.Write([]byte("aaaaasecretaaaaa"))
+	assert.Equal(t, "aaaaa****", b.String())
+	assert.NoError(t, w.Flush())
+	assert.Equal(t, "aaaaa****aaaaa", b.String())
+}
+
+func TestCmdWithMasker(t *testing.T) {
+	_, ok := Which("echo")
+	if !ok {
+		t.Skip("echo not found")
+	}
+
+	var logged []string
+	lines := []string{}
+	m := &replaceMasker{values: []string{"hunter2"}}
+	cmd := New("echo", "password hunter2").WithMasker(m)
+	cmd.SetLogger(func(c *Cmd) {
+		logged = c.Args
+	})
+	cmd.OnStdoutLine(func(line string) {
+		lines = append(lines, line)
+	})
+
+	o, err := cmd.Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "password ****", strings.TrimSpace(o.Text()))
+	assert.Equal(t, []string{"password ****"}, lines)
+	assert.Equal(t, []string{"echo", "password ****"}, logged)
+	assert.Equal(t, []string{"echo", "password hunter2"}, cmd.Args)
+}
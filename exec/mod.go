@@ -8,6 +8,9 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hyprxlabs/go/cmdargs"
@@ -25,16 +28,68 @@ var (
 
 type Cmd struct {
 	*exec.Cmd
-	ctx           *context.Context // if true, the command is a context command
-	logger        func(cmd *Cmd)
-	disableLogger bool
+	ctx            *context.Context // if true, the command is a context command
+	logger         func(cmd *Cmd)
+	disableLogger  bool
+	onStdoutLine   func(line string)
+	onStderrLine   func(line string)
+	maxLineLength  int
+	masker         Masker
+	maskWriters    []*maskWriter
+	rawStdout      bool // stdout feeds another command and is never masked
+	retry          *RetryPolicy
+	timeout        time.Duration
+	gracePeriod    time.Duration
+	stopSignal     os.Signal
+	group          bool // the command runs in its own process group
+	processGroup   bool
+	timedOut       int32
+	mu             sync.Mutex
+	done           chan struct{} // closed once the started command has exited
+	stopping       bool          // Stop was called before the command started
+	stopped        bool
+	finished       bool // the last run ended, Stop has no effect until the next
+	ignoreExitCode bool
+	stdio          stdioFunc
+	cleanup        []func()
+	managed        bool // Run, Output, Quiet or RunAndCapture is in progress
+	eventSource    *eventSource
+	dryRun         bool
+	recorder       *Recorder
+	runner         Runner
+	envMode        int
+	envAllow       []string
+	envSet         map[string]envVar
+	envUnset       map[string]bool
+	envAppend      []string
+	envPrepend     []string
+	ready          *readiness
+	onEvent        EventHandler
+	startedAt      time.Time
+	runAs          string
+	outputLimit    *OutputLimit
+	script         *scriptFile
+	interactive    bool // stdout and stderr share one writer, see Interact
+	pty            *PtySize
+	ptmx           *os.File
+	ptyDone        chan struct{}
 }
 
+// stdioFunc adjusts the stdio selected for a pipeline stage right
+// before it starts, e.g. to apply shell redirections.
+type stdioFunc func(stdin io.Reader, stdout, stderr io.Writer) (io.Reader, io.Writer, io.Writer)
+
 func New(name string, args ...string) *Cmd {
 	cmd := exec.Command(name, args...)
 	return &Cmd{Cmd: cmd}
 }
 
+// NewContext creates a command bound to ctx. When ctx is cancelled the
+// command is stopped gracefully like Stop: the stop signal is sent and
+// it is killed after the grace period. Unless stdin is a terminal, the
+// command runs in its own process group so its descendants are stopped
+// as well; a command reading from the terminal keeps the terminal's
+// process group and only the command itself is signalled.
 func NewContext(ctx context.Context, name string, args ...string) *Cmd {
 	cmd := exec.CommandContext(ctx, name, args...)
 	return &Cmd{Cmd: cmd, ctx: &ctx}
@@ -124,20 +179,32 @@ func (c *Cmd) WithArgs(args ...string) *Cmd {
 	return c
 }
 
+// AppendEnv adds variables on top of the environment, i.e. the one set
+// with WithEnv or else the inherited environment for the env mode.
 func (c *Cmd) AppendEnv(env ...string) *Cmd {
-	c.Cmd.Env = append(c.Cmd.Env, env...)
+	c.envAppend = append(c.envAppend, env...)
 	return c
 }
 
+// PrependEnv adds variables below the environment. Values from the
+// environment win for duplicate keys, so the variables act as
+// defaults.
 func (c *Cmd) PrependEnv(env ...string) *Cmd {
-	c.Cmd.Env = append([]string{env[0]}, c.Cmd.Env...)
+	c.envPrepend = append(c.envPrepend, env...)
 	return c
 }
 
+// WithEnvMap replaces the environment with the variables sorted by key.
 func (c *Cmd) WithEnvMap(env map[string]string) *Cmd {
-	data := make([]string, 0)
-	for k, v := range env {
-		data = append(data, k+"="+v)
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	data := make([]string, 0, len(keys))
+	for _, k := range keys {
+		data = append(data, k+"="+env[k])
 	}
 	return c.WithEnv(data...)
 }
@@ -167,6 +234,30 @@ func (c *Cmd) WithStderr(stderr io.Writer) *Cmd {
 	return c
 }
 
+// OnStdoutLine registers a callback that is invoked for each line
+// written to stdout as soon as the line is complete. The callback
+// fires for Run, Output and Quiet and does not affect what is
+// captured into the Result.
+func (c *Cmd) OnStdoutLine(f func(line string)) *Cmd {
+	c.onStdoutLine = f
+	return c
+}
+
+// OnStderrLine registers a callback that is invoked for each line
+// written to stderr as soon as the line is complete.
+func (c *Cmd) OnStderrLine(f func(line string)) *Cmd {
+	c.onStderrLine = f
+	return c
+}
+
+// WithMaxLineLength sets the maximum number of bytes buffered for
+// a single line before the line callbacks are invoked with a partial
+// line. Defaults to DefaultMaxLineLength.
+func (c *Cmd) WithMaxLineLength(n int) *Cmd {
+	c.maxLineLength = n
+	return c
+}
+
 func (c *Cmd) WithStdio(stdin, stdout, stderr int) *Cmd {
 	switch stdin {
 	case STDIO_INHERIT:
@@ -198,10 +289,87 @@ func (c *Cmd) WithStdio(stdin, stdout, stderr int) *Cmd {
 	return c
 }
 
+// WithIgnoreExitCode controls whether a non-zero exit code is reported
+// as an error by Run, Output, Quiet and RunAndCapture. When ignored,
+// the exit code is only available through Result.Code.
+func (c *Cmd) WithIgnoreExitCode(ignore bool) *Cmd {
+	c.ignoreExitCode = ignore
+	return c
+}
+
 // Runs the command quietly, without any PsOutput
 func (c *Cmd) Quiet() (*Result, error) {
-	c.Cmd.Stdout = nil
-	c.Cmd.Stderr = nil
+	return c.exec(modeQuiet)
+}
+
+// Runs the command and waits for it to finish
+// PsOutputs are inherited from the current process and
+// are not captured
+func (c *Cmd) Run() (*Result, error) {
+	return c.exec(modeInherit)
+}
+
+// Runs the command and captures the PsOutput
+// PsOutputs are captured from the current process and
+// are not inherited
+func (c *Cmd) Output() (*Result, error) {
+	return c.exec(modeCapture)
+}
+
+// RunAndCapture runs the command with stdio inherited from the
+// current process while also capturing stdout and stderr into
+// the Result, similar to piping the output through tee.
+func (c *Cmd) RunAndCapture() (*Result, error) {
+	return c.exec(modeTee)
+}
+
+type runMode int
+
+const (
+	modeInherit runMode = iota
+	modeCapture
+	modeQuiet
+	modeTee
+)
+
+// inherits reports whether the mode writes to the stdio of the
+// current process.
+func (m runMode) inherits() bool {
+	return m == modeInherit || m == modeTee
+}
+
+// captures reports whether the mode captures output into the Result.
+func (m runMode) captures() bool {
+	return m == modeCapture || m == modeTee
+}
+
+func (c *Cmd) exec(mode runMode) (*Result, error) {
+	c.begin()
+	// a command that has run before is reset so it can run again.
+	if c.Cmd.ProcessState != nil {
+		c.reset()
+	}
+
+	c.managed = true
+	defer func() {
+		c.managed = false
+		c.runCleanup()
+		c.finish()
+	}()
+
+	if c.isDryRun() {
+		logDryRun(c)
+		return c.dryRunResult(), nil
+	}
+
+	if c.retry != nil {
+		return c.execRetry(mode)
+	}
+
+	return c.execOnce(mode)
+}
+
+func (c *Cmd) execOnce(mode runMode) (*Result, error) {
 	var out Result
 	out.FileName = c.Cmd.Path
 	out.Args = c.Cmd.Args
@@ -210,106 +378,174 @@ func (c *Cmd) Quiet() (*Result, error) {
 	// use utc time
 	out.StartedAt = time.Now().UTC()
 
-	err := c.Start()
+	outb, errb, err := c.newCaptures(mode)
 	if err != nil {
-		return nil, err
+		out.EndedAt = time.Now().UTC()
+		out.Code = -1
+		return &out, newStartError(c.Cmd.Path, err)
 	}
 
-	err = c.Wait()
-	if err != nil {
-		return nil, err
+	stdout := newStream(c.onStdoutLine, c.maxLineLength)
+	stderr := newStream(c.onStderrLine, c.maxLineLength)
+	if mode.inherits() {
+		stdout.Add(os.Stdout)
+		stderr.Add(os.Stderr)
+		c.Cmd.Stdin = os.Stdin
 	}
-	out.EndedAt = time.Now().UTC()
-	out.Code = c.Cmd.ProcessState.ExitCode()
 
-	return &out, nil
-}
+	if mode.captures() {
+		stdout.Add(outb)
+		stderr.Add(errb)
+	}
 
-// Runs the command and waits for it to finish
-// PsOutputs are inherited from the current process and
-// are not captured
-func (c *Cmd) Run() (*Result, error) {
-	c.Cmd.Stdout = os.Stdout
-	c.Cmd.Stderr = os.Stderr
-	c.Cmd.Stdin = os.Stdin
-	var out Result
-	out.FileName = c.Cmd.Path
-	out.Args = c.Cmd.Args
-	// use utc time
-	out.StartedAt = time.Now().UTC()
-	out.Stdout = make([]byte, 0)
-	out.Stderr = make([]byte, 0)
+	c.Cmd.Stdout = stdout.Writer()
+	c.Cmd.Stderr = stderr.Writer()
+	if r := c.getRunner(); r != nil {
+		return c.execRunner(r, mode, stdout, stderr, outb, errb)
+	}
 
-	err := c.Start()
+	err = c.Start()
 	if err != nil {
+		outb.discard()
+		errb.discard()
 		out.EndedAt = time.Now().UTC()
-		out.Code = 1
+		out.Code = -1
+		c.record(&out, false)
 		return &out, err
 	}
 
 	err = c.Wait()
+	stdout.Close()
+	stderr.Close()
+	out.EndedAt = time.Now().UTC()
+	out.TimedOut = c.TimedOut()
+	out.Signal = exitSignal(c.Cmd.ProcessState)
+	out.Code = exitCode(c.Cmd.ProcessState)
+	out.Usage = resourceUsage(c.Cmd.ProcessState)
+	if mode.captures() {
+		if ferr := fillOutput(&out, outb, errb); ferr != nil && err == nil {
+			err = ferr
+		}
+	}
+
+	c.record(&out, false)
 	if err != nil {
-		out.EndedAt = time.Now().UTC()
-		out.Code = 1
+		var ee *ExitError
+		if c.ignoreExitCode && !out.TimedOut && errors.As(err, &ee) {
+			return &out, nil
+		}
+
 		return &out, err
 	}
 
-	out.EndedAt = time.Now().UTC()
-	out.Code = c.Cmd.ProcessState.ExitCode()
 	return &out, nil
 }
 
-// Runs the command and captures the PsOutput
-// PsOutputs are captured from the current process and
-// are not inherited
-func (c *Cmd) Output() (*Result, error) {
+// reset replaces the underlying os/exec command with a fresh copy so
+// the command can be started again.
+func (c *Cmd) reset() {
+	old := c.Cmd
+	var next *exec.Cmd
+	if c.ctx != nil {
+		next = exec.CommandContext(*c.ctx, old.Path)
+	} else {
+		next = exec.Command(old.Path)
+	}
 
-	var out Result
-	out.Stdout = make([]byte, 0)
-	out.Stderr = make([]byte, 0)
-	out.StartedAt = time.Now().UTC()
-	out.FileName = c.Cmd.Path
-	out.Args = c.Cmd.Args
+	next.Path = old.Path
+	next.Args = old.Args
+	c.replace(next)
+	c.maskWriters = nil
+}
 
-	var outb, errb bytes.Buffer
-	c.Stdout = &outb
-	c.Stderr = &errb
+// replace swaps the underlying os/exec command for next and carries
+// over everything but the path and arguments.
+func (c *Cmd) replace(next *exec.Cmd) {
+	old := c.Cmd
+	next.Env = old.Env
+	next.Dir = old.Dir
+	next.Stdin = old.Stdin
+	next.Stdout = old.Stdout
+	next.Stderr = old.Stderr
+	next.ExtraFiles = old.ExtraFiles
+	next.SysProcAttr = old.SysProcAttr
+	c.mu.Lock()
+	c.Cmd = next
+	c.mu.Unlock()
+}
 
-	err := c.Start()
-	if err != nil {
-		out.EndedAt = time.Now().UTC()
-		out.Code = 1
-		return &out, err
+func (c *Cmd) Start() error {
+	if c.getRunner() != nil {
+		return &StartError{FileName: c.Cmd.Path, Err: ErrRunnerStart}
 	}
 
-	err = c.Wait()
+	if c.ready != nil {
+		c.ready.tap(c)
+	}
+
+	c.tapEvents()
+	c.maskOutput()
+	atomic.StoreInt32(&c.timedOut, 0)
+	if (c.timeout > 0 || c.processGroup || (c.cancelable() && !c.stdinIsTerminal())) && c.pty == nil {
+		c.group = setProcessGroup(c.Cmd)
+	}
+
+	c.rewindStdin()
+	restoreEnv := c.applyEnv()
+	if !c.disableLogger {
+		c.logStart()
+		c.resolvePath()
+	}
+
+	c.startedAt = time.Now()
+	err := c.launch()
+	restoreEnv()
+	c.emitStarted(err)
 	if err != nil {
-		out.EndedAt = time.Now().UTC()
-		out.Code = 1
-		return &out, err
+		if c.ready != nil {
+			c.ready.exited = nil
+		}
+
+		return err
 	}
 
-	out.EndedAt = time.Now().UTC()
-	out.Code = c.Cmd.ProcessState.ExitCode()
-	out.Stdout = outb.Bytes()
-	out.Stderr = errb.Bytes()
+	if c.ready != nil {
+		c.ready.watch(c)
+	}
 
-	return &out, nil
+	return nil
 }
 
-func (c *Cmd) Start() error {
-	if c.disableLogger {
-		return c.Cmd.Start()
+// logStart passes the masked command to the configured loggers.
+func (c *Cmd) logStart() {
+	if c.disableLogger || (c.logger == nil && logger == nil) {
+		return
 	}
 
+	lc := c.masked()
 	if c.logger != nil {
-		c.logger(c)
+		c.logger(lc)
 	}
 
 	if logger != nil {
-		logger(c)
+		logger(lc)
+	}
+}
+
+// String returns the command line with the arguments quoted where
+// needed, in the same form as dry runs. Loggers receive a masked
+// command, so its String is safe to log.
+func (c *Cmd) String() string {
+	args := c.Cmd.Args
+	if len(args) > 0 {
+		args = append([]string{c.Cmd.Path}, args[1:]...)
 	}
 
+	return cmdargs.New(args).String()
+}
+
+// resolvePath resolves a relative command path with Find.
+func (c *Cmd) resolvePath() {
 	p := c.Cmd.Path
 	if p != "" && !filepath.IsAbs(p) {
 		p2, err := Find(p, nil)
@@ -317,10 +553,146 @@ func (c *Cmd) Start() error {
 			c.Cmd.Path = p2
 		}
 	}
+}
+
+func (c *Cmd) launch() error {
+	if err := c.applyUser(); err != nil {
+		if !c.managed {
+			c.runCleanup()
+		}
+
+		return newStartError(c.Cmd.Path, err)
+	}
+
+	if err := c.writeScript(); err != nil {
+		if !c.managed {
+			c.runCleanup()
+		}
+
+		return newStartError(c.Cmd.Path, err)
+	}
+
+	if c.pty != nil {
+		return c.startPty()
+	}
+
+	return c.start()
+}
+
+func (c *Cmd) start() error {
+	done := make(chan struct{})
+	c.setCancel(done)
+	c.snapshotEvents()
+	err := c.Cmd.Start()
+	if err != nil {
+		if !c.managed {
+			c.runCleanup()
+		}
+
+		return newStartError(c.Cmd.Path, err)
+	}
+
+	c.mu.Lock()
+	c.done = done
+	stop := c.stopping
+	c.stopping = false
+	c.mu.Unlock()
 
-	return c.Cmd.Start()
+	c.watchTimeout(c.Cmd.Process, done)
+	c.watchContext(done)
+	if stop {
+		go c.terminate(c.Cmd.Process, done)
+	}
+
+	return nil
 }
 
 func (c *Cmd) Wait() error {
-	return c.Cmd.Wait()
+	// with readiness probes the command is already waited for in the
+	// background.
+	if c.ready != nil && c.ready.exited != nil {
+		<-c.ready.exited
+		return c.ready.err
+	}
+
+	return c.wait()
+}
+
+func (c *Cmd) wait() error {
+	err := c.Cmd.Wait()
+	c.waitPty()
+	c.mu.Lock()
+	if c.done != nil {
+		close(c.done)
+		c.done = nil
+	}
+	c.mu.Unlock()
+
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		err = &ExitError{
+			FileName: c.Cmd.Path,
+			Code:     exitCode(ee.ProcessState),
+			Signal:   exitSignal(ee.ProcessState),
+			TimedOut: c.TimedOut(),
+			Timeout:  c.timeout,
+			Err:      err,
+		}
+	}
+
+	for _, w := range c.maskWriters {
+		if ferr := w.Flush(); ferr != nil && err == nil {
+			err = ferr
+		}
+	}
+
+	c.maskWriters = nil
+	c.emitExited(exitCode(c.Cmd.ProcessState), exitSignal(c.Cmd.ProcessState), err)
+	if !c.managed {
+		c.runCleanup()
+		c.finish()
+	}
+
+	return err
+}
+
+// runCleanup runs and clears the registered cleanup functions, e.g.
+// removing temporary script files.
+func (c *Cmd) runCleanup() {
+	for _, f := range c.cleanup {
+		f()
+	}
+
+	c.cleanup = nil
+}
+
+// maskOutput wraps stdout and stderr with masking writers when
+// a masker is configured.
+func (c *Cmd) maskOutput() {
+	m := c.getMasker()
+	if m == nil {
+		return
+	}
+
+	// interactive output is masked as it arrives so prompts without a
+	// trailing newline are not held back.
+	if c.interactive {
+		w := newMaskWriter(m, c.Cmd.Stdout, c.maxLineLength)
+		w.chunked = true
+		c.Cmd.Stdout = w
+		c.Cmd.Stderr = w
+		return
+	}
+
+	if c.Cmd.Stdout != nil && !c.rawStdout {
+		w := newMaskWriter(m, c.Cmd.Stdout, c.maxLineLength)
+		c.maskWriters = append(c.maskWriters, w)
+		c.Cmd.Stdout = w
+	}
+
+	if c.Cmd.Stderr != nil {
+		w := newMaskWriter(m, c.Cmd.Stderr, c.maxLineLength)
+		c.maskWriters = append(c.maskWriters, w)
+		c.Cmd.Stderr = w
+	}
 }
@@ -3,6 +3,18 @@
 
 package exec
 
+import "os"
+
 const (
 	EOL = "\n" // POSIX line endings
 )
+
+// envKey normalizes an environment variable key for comparison.
+func envKey(key string) string {
+	return key
+}
+
+// isExecutable reports whether the file has an executable bit set.
+func isExecutable(path string, fi os.FileInfo) bool {
+	return fi.Mode()&0o111 != 0
+}
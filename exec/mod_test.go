@@ -47,3 +47,15 @@ func TestPipeCommand(t *testing.T) {
 	assert.Equal(t, 0, o.Code)
 	assert.Equal(t, "Hello World", strings.TrimSpace(o.Text()))
 }
+
+func TestRunAndCapture(t *testing.T) {
+	_, ok := exec.Which("echo")
+	if !ok {
+		t.Skip("echo not found")
+	}
+
+	o, err := exec.New("echo", "hello").RunAndCapture()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, o.Code)
+	assert.Equal(t, "hello", strings.TrimSpace(o.Text()))
+}
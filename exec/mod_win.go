@@ -4,6 +4,36 @@
 // +go:build windows
 package exec
 
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
 const (
 	EOL = "\r\n" // Windows line endings
 )
+
+// envKey normalizes an environment variable key for comparison.
+// Keys are case-insensitive on Windows.
+func envKey(key string) string {
+	return strings.ToUpper(key)
+}
+
+// isExecutable reports whether the file has an extension listed in
+// PATHEXT.
+func isExecutable(path string, fi os.FileInfo) bool {
+	pathExt := os.Getenv("PATHEXT")
+	if pathExt == "" {
+		pathExt = ".com;.exe;.bat;.cmd;.vbs;.vbe;.js;.jse;.wsf;.wsh"
+	}
+
+	ext := filepath.Ext(path)
+	for _, n := range strings.Split(pathExt, ";") {
+		if ext != "" && strings.EqualFold(n, ext) {
+			return true
+		}
+	}
+
+	return false
+}
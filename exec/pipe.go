@@ -1,17 +1,87 @@
 package exec
 
 import (
-	"bytes"
 	"context"
 	"errors"
-	"io"
 	"os"
+	"sync"
 	"time"
 )
 
 type Pipeline struct {
-	cmds []*Cmd
-	ctx  *context.Context // if true, the command is a context command
+	cmds          []*Cmd
+	ctx           *context.Context // if true, the command is a context command
+	onStdoutLine  func(line string)
+	onStderrLine  func(line string)
+	maxLineLength int
+	pipefail      bool
+}
+
+// PipelineResult is the result of running a pipeline. The embedded
+// Result describes the pipeline as a whole: its output is the output
+// of the last command and its code follows the pipefail setting.
+type PipelineResult struct {
+	*Result
+	// Stages holds one result per command in the pipeline, in order.
+	// Stdout is only captured for the last stage; stderr is captured
+	// for every stage.
+	Stages []*Result
+}
+
+// PipelineError is returned when one or more stages of a
+// pipeline fail.
+type PipelineError struct {
+	Errors []error
+}
+
+func (e *PipelineError) Error() string {
+	msg := "Pipeline execution failed with errors: "
+	for _, err := range e.Errors {
+		msg += err.Error() + ";\n"
+	}
+
+	return msg
+}
+
+// Unwrap returns the error of the right most failing stage.
+func (e *PipelineError) Unwrap() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+
+	return e.Errors[len(e.Errors)-1]
+}
+
+// WithPipeFail enables pipefail semantics, similar to bash's
+// set -o pipefail. When enabled, the pipeline fails if any command
+// fails and the code is that of the right most failing command.
+// Otherwise only the last command determines the outcome.
+func (p *Pipeline) WithPipeFail(enabled bool) *Pipeline {
+	p.pipefail = enabled
+	return p
+}
+
+// OnStdoutLine registers a callback that is invoked for each line
+// written to stdout by the last command in the pipeline.
+func (p *Pipeline) OnStdoutLine(f func(line string)) *Pipeline {
+	p.onStdoutLine = f
+	return p
+}
+
+// OnStderrLine registers a callback that is invoked for each line
+// written to stderr by any command in the pipeline. Calls are
+// serialized so the callback does not need to be safe for
+// concurrent use.
+func (p *Pipeline) OnStderrLine(f func(line string)) *Pipeline {
+	p.onStderrLine = f
+	return p
+}
+
+// WithMaxLineLength sets the maximum number of bytes buffered for
+// a single line before the line callbacks are invoked.
+func (p *Pipeline) WithMaxLineLength(n int) *Pipeline {
+	p.maxLineLength = n
+	return p
 }
 
 func (p *Pipeline) Pipe(subcommands ...*Cmd) *Pipeline {
@@ -63,196 +133,237 @@ func (c *Cmd) PipeCommand(subcommands ...string) *Pipeline {
 	return p
 }
 
-func (p *Pipeline) Output() (*Result, error) {
-	var o Result
-	o.Stdout = make([]byte, 0)
-	o.Stderr = make([]byte, 0)
-	o.StartedAt = time.Now().UTC()
+// Output runs the pipeline and captures the output of every command.
+func (p *Pipeline) Output() (*PipelineResult, error) {
+	return p.exec(modeCapture)
+}
 
-	lastIndex := len(p.cmds) - 1
-	r, w := io.Pipe()
+// Run runs the pipeline with stderr of every command and stdout of
+// the last command inherited from the current process.
+func (p *Pipeline) Run() (*PipelineResult, error) {
+	return p.exec(modeInherit)
+}
 
-	errs := make([]error, 0)
-	count := 0
-	var outb, errb bytes.Buffer
+// RunAndCapture runs the pipeline with output written to the current
+// process's stdio and captured into the result.
+func (p *Pipeline) RunAndCapture() (*PipelineResult, error) {
+	return p.exec(modeTee)
+}
+
+func (p *Pipeline) exec(mode runMode) (*PipelineResult, error) {
+	n := len(p.cmds)
+	res := &PipelineResult{Result: &Result{}, Stages: make([]*Result, n)}
+	res.Stdout = make([]byte, 0)
+	res.Stderr = make([]byte, 0)
+	res.StartedAt = time.Now().UTC()
+	if n == 0 {
+		res.EndedAt = res.StartedAt
+		return res, errors.New("pipeline has no commands")
+	}
 
-	prev := p.cmds[0]
+	if p.isDryRun() {
+		logDryRun(p.cmds...)
+		for i, cmd := range p.cmds {
+			res.Stages[i] = cmd.dryRunResult()
+		}
+
+		*res.Result = *res.Stages[n-1]
+		return res, nil
+	}
+
+	if p.hasRunner() {
+		return p.execRunner(mode, res)
+	}
+
+	var mu sync.Mutex
+	onStderr := p.onStderrLine
+	if onStderr != nil {
+		onStderr = func(line string) {
+			mu.Lock()
+			defer mu.Unlock()
+			p.onStderrLine(line)
+		}
+	}
+
+	outb, errbs, err := newPipelineCaptures(p.cmds, mode)
+	if err != nil {
+		return p.notStarted(res, err)
+	}
+
+	streams := make([]*stream, 0, n+1)
+	started := make([]bool, n)
+	errs := make([]error, n)
+
+	var stdin *os.File
 	for i, cmd := range p.cmds {
-		count++
-		if i == 0 {
-			cmd.Stdout = w
-			err := cmd.Start()
-			if err != nil {
-				errs = append(errs, err)
-				break
-			}
-		} else if i == lastIndex {
-			cmd.Stdin = r
-			cmd.Stdout = &outb
-			cmd.Stderr = &errb
-			err := cmd.Start()
-			if err != nil {
-				errs = append(errs, err)
-				break
-			}
+		stage := &Result{FileName: cmd.Path, Args: cmd.Args, Stdout: make([]byte, 0), Stderr: make([]byte, 0), Code: -1}
+		res.Stages[i] = stage
 
-			err = prev.Wait()
-			if err != nil {
-				errs = append(errs, err)
-			}
+		// a previous stage failed to start, don't start the rest.
+		if i > 0 && stdin == nil {
+			errs[i] = &StartError{FileName: cmd.Path, Err: errors.New("previous pipeline stage did not start")}
+			continue
+		}
 
-			err = w.Close()
-			if err != nil {
-				errs = append(errs, err)
-			}
+		var stdinR, stdoutW *os.File
+		if i > 0 {
+			stdinR = stdin
+			cmd.Stdin = stdin
+		}
 
-			err = cmd.Wait()
-			o.EndedAt = time.Now().UTC()
+		if i < n-1 {
+			r, w, err := os.Pipe()
 			if err != nil {
-				errs = append(errs, err)
+				errs[i] = &StartError{FileName: cmd.Path, Err: err}
+				closeFile(stdin)
+				stdin = nil
+				continue
 			}
 
-			o.FileName = cmd.Path
-			o.Args = cmd.Args
-			o.Code = cmd.Cmd.ProcessState.ExitCode()
-			o.Stdout = outb.Bytes()
-			o.Stderr = errb.Bytes()
+			cmd.Stdout = w
+			cmd.rawStdout = true
+			stdoutW = w
+			stdin = r
 		} else {
-			r2, w2 := io.Pipe()
-			cmd.Stdin = r
-			cmd.Stdout = w2
-			err := cmd.Start()
-			if err != nil {
-				errs = append(errs, err)
-				break
+			stdout := newStream(p.onStdoutLine, p.maxLineLength)
+			if mode.inherits() {
+				stdout.Add(os.Stdout)
 			}
 
-			err = prev.Wait()
-			if err != nil {
-				errs = append(errs, err)
+			if mode.captures() {
+				stdout.Add(outb)
 			}
 
-			err = w.Close()
-			if err != nil {
-				errs = append(errs, err)
-			}
-
-			w = w2
-			prev = cmd
-			if err != r.Close() {
-				errs = append(errs, err)
-			}
+			cmd.Stdout = stdout.Writer()
+			streams = append(streams, stdout)
+		}
 
-			r = r2
+		stderr := newStream(onStderr, p.maxLineLength)
+		if mode.inherits() {
+			stderr.Add(os.Stderr)
 		}
-	}
 
-	if len(errs) > 0 {
-		msg := "Pipeline execution failed with errors: "
-		for _, err := range errs {
-			if err != nil {
-				msg += err.Error() + ";\n"
-			}
+		if mode.captures() {
+			stderr.Add(errbs[i])
 		}
-		e := errors.New(msg)
 
-		return &o, e
-	}
+		cmd.Stderr = stderr.Writer()
+		streams = append(streams, stderr)
+		if cmd.stdio != nil {
+			cmd.Stdin, cmd.Stdout, cmd.Stderr = cmd.stdio(cmd.Stdin, cmd.Stdout, cmd.Stderr)
+		}
 
-	return &o, nil
-}
+		stage.StartedAt = time.Now().UTC()
+		err := cmd.Start()
 
-func (p *Pipeline) Run() (*Result, error) {
-	var o Result
-	o.Stdout = make([]byte, 0)
-	o.Stderr = make([]byte, 0)
-	o.StartedAt = time.Now().UTC()
+		// the children own the pipe ends now.
+		closeFile(stdoutW)
+		closeFile(stdinR)
 
-	lastIndex := len(p.cmds) - 1
-	r, w := io.Pipe()
+		if err != nil {
+			errs[i] = err
+			stage.EndedAt = time.Now().UTC()
+			cmd.record(stage, false)
+			if i < n-1 {
+				closeFile(stdin)
+				stdin = nil
+			}
+			continue
+		}
 
-	errs := make([]error, len(p.cmds))
-	count := 0
+		started[i] = true
+	}
 
-	prev := p.cmds[0]
 	for i, cmd := range p.cmds {
-		count++
-		if i == 0 {
-			cmd.Stdout = w
-			err := cmd.Start()
-			if err != nil {
-				errs = append(errs, err)
-				break
-			}
-		} else if i == lastIndex {
-			cmd.Stdin = r
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			err := cmd.Start()
-			if err != nil {
-				errs = append(errs, err)
-				break
-			}
+		if !started[i] {
+			continue
+		}
 
-			err = prev.Wait()
-			if err != nil {
-				errs = append(errs, err)
-			}
+		stage := res.Stages[i]
+		errs[i] = cmd.Wait()
+		stage.EndedAt = time.Now().UTC()
+		stage.Code = exitCode(cmd.Cmd.ProcessState)
+		stage.Signal = exitSignal(cmd.Cmd.ProcessState)
+		stage.TimedOut = cmd.TimedOut()
+		stage.Usage = resourceUsage(cmd.Cmd.ProcessState)
+		cmd.record(stage, false)
+	}
 
-			err = w.Close()
-			if err != nil {
-				errs = append(errs, err)
-			}
+	for _, s := range streams {
+		s.Close()
+	}
 
-			err = cmd.Wait()
-			o.EndedAt = time.Now().UTC()
-			if err != nil {
-				errs = append(errs, err)
+	if mode.captures() {
+		for i, stage := range res.Stages {
+			stdout := &capture{head: make([]byte, 0)}
+			if i == n-1 {
+				stdout = outb
 			}
 
-			o.FileName = cmd.Path
-			o.Args = cmd.Args
-			o.Code = cmd.Cmd.ProcessState.ExitCode()
-		} else {
-			r2, w2 := io.Pipe()
-			cmd.Stdin = r
-			cmd.Stdout = w2
-			err := cmd.Start()
-			if err != nil {
-				errs = append(errs, err)
-				break
+			if err := fillOutput(stage, stdout, errbs[i]); err != nil && errs[i] == nil {
+				errs[i] = err
 			}
+		}
+	}
 
-			err = prev.Wait()
-			if err != nil {
-				errs = append(errs, err)
-			}
+	return p.finish(res, errs)
+}
 
-			err = w.Close()
-			if err != nil {
-				errs = append(errs, err)
-			}
+// notStarted fills the result when the pipeline could not be started.
+func (p *Pipeline) notStarted(res *PipelineResult, err error) (*PipelineResult, error) {
+	for i, cmd := range p.cmds {
+		res.Stages[i] = notStarted(cmd)
+	}
 
-			w = w2
-			prev = cmd
-			if err != r.Close() {
-				errs = append(errs, err)
-			}
+	*res.Result = *res.Stages[len(p.cmds)-1]
+	res.EndedAt = time.Now().UTC()
+	return res, err
+}
+
+// finish fills the pipeline result from the last stage and reports
+// failures according to the pipefail setting.
+func (p *Pipeline) finish(res *PipelineResult, errs []error) (*PipelineResult, error) {
+	n := len(res.Stages)
+	*res.Result = *res.Stages[n-1]
+	res.StartedAt = res.Stages[0].StartedAt
+	res.EndedAt = time.Now().UTC()
+
+	failed := make([]error, 0)
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
 
-			r = r2
+		var ee *ExitError
+		if !p.pipefail && i < n-1 && errors.As(err, &ee) {
+			continue
+		}
+
+		failed = append(failed, err)
+		if p.pipefail && res.Stages[i].Code != 0 {
+			res.Code = res.Stages[i].Code
 		}
 	}
 
-	if len(errs) > 0 {
-		msg := "Pipeline execution failed with errors: "
-		for _, err := range errs {
-			if err != nil {
-				msg += err.Error() + ";\n"
-			}
+	if len(failed) > 0 {
+		return res, &PipelineError{Errors: failed}
+	}
+
+	return res, nil
+}
+
+func (p *Pipeline) isDryRun() bool {
+	for _, cmd := range p.cmds {
+		if cmd.isDryRun() {
+			return true
 		}
-		e := errors.New(msg)
-		return &o, e
 	}
 
-	return &o, nil
+	return false
+}
+
+func closeFile(f *os.File) {
+	if f != nil {
+		_ = f.Close()
+	}
 }
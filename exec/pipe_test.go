@@ -0,0 +1,47 @@
+package exec_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hyprxlabs/go/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipelineStages(t *testing.T) {
+	_, hasSh := exec.Which("sh")
+	_, hasCat := exec.Which("cat")
+	if !hasSh || !hasCat {
+		t.Skip("sh or cat not found")
+	}
+
+	first := exec.New("sh", "-c", "echo one; echo first >&2")
+	second := exec.New("sh", "-c", "cat; echo second >&2")
+	o, err := first.Pipe(second, exec.New("cat")).Output()
+	assert.NoError(t, err)
+	assert.Len(t, o.Stages, 3)
+	assert.Equal(t, "one", strings.TrimSpace(o.Text()))
+	assert.Equal(t, "first\n", string(o.Stages[0].Stderr))
+	assert.Equal(t, "second\n", string(o.Stages[1].Stderr))
+	for _, stage := range o.Stages {
+		assert.Equal(t, 0, stage.Code)
+	}
+}
+
+func TestPipelinePipeFail(t *testing.T) {
+	_, hasSh := exec.Which("sh")
+	_, hasCat := exec.Which("cat")
+	if !hasSh || !hasCat {
+		t.Skip("sh or cat not found")
+	}
+
+	o, err := exec.New("sh", "-c", "exit 2").Pipe(exec.New("cat")).Output()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, o.Code)
+	assert.Equal(t, 2, o.Stages[0].Code)
+
+	o, err = exec.New("sh", "-c", "exit 2").Pipe(exec.New("cat")).WithPipeFail(true).Output()
+	assert.Error(t, err)
+	assert.Equal(t, 2, o.Code)
+	assert.Equal(t, 0, o.Stages[1].Code)
+}
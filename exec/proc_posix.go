@@ -0,0 +1,113 @@
+//go:build !windows
+// +build !windows
+
+package exec
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+)
+
+var (
+	defaultStopSignal os.Signal = syscall.SIGTERM
+)
+
+// setProcessGroup starts the command in a new process group so
+// signals can be delivered to all of its descendants.
+func setProcessGroup(cmd *exec.Cmd) bool {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.Setpgid = true
+	return true
+}
+
+func signalProcess(p *os.Process, sig os.Signal, group bool) error {
+	s, ok := sig.(syscall.Signal)
+	if group && ok {
+		return syscall.Kill(-p.Pid, s)
+	}
+
+	return p.Signal(sig)
+}
+
+func killProcess(p *os.Process, group bool) error {
+	return signalProcess(p, syscall.SIGKILL, group)
+}
+
+// exitSignal returns the name of the signal that terminated the
+// process or an empty string if it exited normally.
+func exitSignal(state *os.ProcessState) string {
+	if state == nil {
+		return ""
+	}
+
+	ws, ok := state.Sys().(syscall.WaitStatus)
+	if !ok || !ws.Signaled() {
+		return ""
+	}
+
+	return signalName(ws.Signal())
+}
+
+// exitCode returns the exit code of the process. Processes killed
+// by a signal report 128 plus the signal number like POSIX shells.
+func exitCode(state *os.ProcessState) int {
+	if state == nil {
+		return -1
+	}
+
+	ws, ok := state.Sys().(syscall.WaitStatus)
+	if ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+
+	return state.ExitCode()
+}
+
+// maxRSS returns the maximum resident set size in bytes.
+func maxRSS(state *os.ProcessState) int64 {
+	ru, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok || ru == nil {
+		return 0
+	}
+
+	// darwin reports bytes, other systems report kilobytes.
+	if runtime.GOOS == "darwin" || runtime.GOOS == "ios" {
+		return int64(ru.Maxrss)
+	}
+
+	return int64(ru.Maxrss) * 1024
+}
+
+func signalName(sig syscall.Signal) string {
+	switch sig {
+	case syscall.SIGHUP:
+		return "SIGHUP"
+	case syscall.SIGINT:
+		return "SIGINT"
+	case syscall.SIGQUIT:
+		return "SIGQUIT"
+	case syscall.SIGABRT:
+		return "SIGABRT"
+	case syscall.SIGKILL:
+		return "SIGKILL"
+	case syscall.SIGSEGV:
+		return "SIGSEGV"
+	case syscall.SIGPIPE:
+		return "SIGPIPE"
+	case syscall.SIGALRM:
+		return "SIGALRM"
+	case syscall.SIGTERM:
+		return "SIGTERM"
+	case syscall.SIGUSR1:
+		return "SIGUSR1"
+	case syscall.SIGUSR2:
+		return "SIGUSR2"
+	default:
+		return sig.String()
+	}
+}
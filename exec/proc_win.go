@@ -0,0 +1,71 @@
+//go:build windows
+// +build windows
+
+package exec
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+var (
+	defaultStopSignal os.Signal = os.Interrupt
+)
+
+// setProcessGroup starts the command in a new process group so
+// the whole tree can be terminated with taskkill.
+func setProcessGroup(cmd *exec.Cmd) bool {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+	return true
+}
+
+// signalProcess asks the process tree to exit. Windows does not
+// support delivering signals, so taskkill without /F is used which
+// sends a close request to the processes.
+func signalProcess(p *os.Process, sig os.Signal, group bool) error {
+	if sig == os.Kill {
+		return killProcess(p, group)
+	}
+
+	if !group {
+		return p.Signal(sig)
+	}
+
+	return exec.Command("taskkill", "/T", "/PID", strconv.Itoa(p.Pid)).Run()
+}
+
+func killProcess(p *os.Process, group bool) error {
+	if !group {
+		return p.Kill()
+	}
+
+	err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(p.Pid)).Run()
+	if err != nil {
+		return p.Kill()
+	}
+
+	return nil
+}
+
+func exitSignal(state *os.ProcessState) string {
+	return ""
+}
+
+func exitCode(state *os.ProcessState) int {
+	if state == nil {
+		return -1
+	}
+
+	return state.ExitCode()
+}
+
+// maxRSS is not reported by os.ProcessState on Windows.
+func maxRSS(state *os.ProcessState) int64 {
+	return 0
+}
@@ -0,0 +1,139 @@
+package exec
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+var (
+	// ErrNotSupported is returned when a feature is not available on
+	// the current operating system.
+	ErrNotSupported = errors.New("not supported on this platform")
+
+	// ErrNotStarted is returned when an operation requires a command
+	// that has been started.
+	ErrNotStarted = errors.New("command has not been started")
+)
+
+// ProcessInfo is a snapshot of a running process.
+type ProcessInfo struct {
+	Pid        int
+	PPid       int
+	Name       string
+	State      string
+	RSS        int64 // resident set size in bytes
+	UserTime   time.Duration
+	SystemTime time.Duration
+}
+
+// ResourceUsage describes the resources used by a command that
+// has exited.
+type ResourceUsage struct {
+	MaxRSS     int64 // maximum resident set size in bytes
+	UserTime   time.Duration
+	SystemTime time.Duration
+}
+
+// Pid returns the process id of the started command or 0.
+func (c *Cmd) Pid() int {
+	if c.Cmd.Process == nil {
+		return 0
+	}
+
+	return c.Cmd.Process.Pid
+}
+
+// ProcessInfo returns a snapshot of the started command's process.
+func (c *Cmd) ProcessInfo() (*ProcessInfo, error) {
+	pid := c.Pid()
+	if pid == 0 {
+		return nil, ErrNotStarted
+	}
+
+	return readProcess(pid)
+}
+
+// ProcessTree returns the started command's process followed by all
+// of its descendants in breadth first order.
+func (c *Cmd) ProcessTree() ([]*ProcessInfo, error) {
+	pid := c.Pid()
+	if pid == 0 {
+		return nil, ErrNotStarted
+	}
+
+	all, err := listProcesses()
+	if err != nil {
+		return nil, err
+	}
+
+	root, ok := all[pid]
+	if !ok {
+		return nil, os.ErrProcessDone
+	}
+
+	children := make(map[int][]*ProcessInfo)
+	for _, p := range all {
+		children[p.PPid] = append(children[p.PPid], p)
+	}
+
+	tree := []*ProcessInfo{root}
+	for i := 0; i < len(tree); i++ {
+		tree = append(tree, children[tree[i].Pid]...)
+	}
+
+	return tree, nil
+}
+
+// Children returns the process ids of the direct children of the
+// started command.
+func (c *Cmd) Children() ([]int, error) {
+	tree, err := c.ProcessTree()
+	if err != nil {
+		return nil, err
+	}
+
+	pids := make([]int, 0)
+	for _, p := range tree[1:] {
+		if p.PPid == tree[0].Pid {
+			pids = append(pids, p.Pid)
+		}
+	}
+
+	return pids, nil
+}
+
+// SignalTree sends the signal to every descendant of the started
+// command, deepest first, and then to the command itself.
+func (c *Cmd) SignalTree(sig os.Signal) error {
+	tree, err := c.ProcessTree()
+	if err != nil {
+		return err
+	}
+
+	var first error
+	for i := len(tree) - 1; i >= 0; i-- {
+		p, err := os.FindProcess(tree[i].Pid)
+		if err == nil {
+			err = p.Signal(sig)
+		}
+
+		if err != nil && first == nil && !errors.Is(err, os.ErrProcessDone) {
+			first = err
+		}
+	}
+
+	return first
+}
+
+func resourceUsage(state *os.ProcessState) *ResourceUsage {
+	if state == nil {
+		return nil
+	}
+
+	return &ResourceUsage{
+		MaxRSS:     maxRSS(state),
+		UserTime:   state.UserTime(),
+		SystemTime: state.SystemTime(),
+	}
+}
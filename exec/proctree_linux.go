@@ -0,0 +1,89 @@
+//go:build linux
+// +build linux
+
+package exec
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is USER_HZ which is 100 on all supported Linux
+// architectures.
+const clockTicks = 100
+
+func listProcesses() (map[int]*ProcessInfo, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	all := make(map[int]*ProcessInfo)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		// processes may exit while the directory is being read.
+		p, err := readProcess(pid)
+		if err != nil {
+			continue
+		}
+
+		all[pid] = p
+	}
+
+	return all, nil
+}
+
+func readProcess(pid int) (*ProcessInfo, error) {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return nil, err
+	}
+
+	return parseProcStat(string(data))
+}
+
+// parseProcStat parses /proc/<pid>/stat. The command name is wrapped
+// in parentheses and may contain spaces, so fields are read after the
+// last closing parenthesis.
+func parseProcStat(stat string) (*ProcessInfo, error) {
+	open := strings.IndexByte(stat, '(')
+	end := strings.LastIndexByte(stat, ')')
+	if open < 0 || end < open {
+		return nil, errors.New("invalid process stat")
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(stat[:open]))
+	if err != nil {
+		return nil, err
+	}
+
+	fields := strings.Fields(stat[end+1:])
+	// fields[0] is the state (field 3), so field n is at index n-3.
+	if len(fields) < 22 {
+		return nil, errors.New("invalid process stat")
+	}
+
+	num := func(n int) int64 {
+		v, _ := strconv.ParseInt(fields[n-3], 10, 64)
+		return v
+	}
+
+	tick := time.Second / clockTicks
+	return &ProcessInfo{
+		Pid:        pid,
+		PPid:       int(num(4)),
+		Name:       stat[open+1 : end],
+		State:      fields[0],
+		UserTime:   time.Duration(num(14)) * tick,
+		SystemTime: time.Duration(num(15)) * tick,
+		RSS:        num(24) * int64(os.Getpagesize()),
+	}, nil
+}
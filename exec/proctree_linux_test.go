@@ -0,0 +1,53 @@
+//go:build linux
+// +build linux
+
+package exec
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProcStat(t *testing.T) {
+	p, err := parseProcStat("42 (my (odd) name) S 7 42 42 0 -1 4194560 100 0 0 0 250 50 0 0 20 0 1 0 100 1000 3 18446744073709551615")
+	assert.NoError(t, err)
+	assert.Equal(t, 42, p.Pid)
+	assert.Equal(t, 7, p.PPid)
+	assert.Equal(t, "my (odd) name", p.Name)
+	assert.Equal(t, "S", p.State)
+	assert.Equal(t, 2500*time.Millisecond, p.UserTime)
+	assert.Equal(t, 500*time.Millisecond, p.SystemTime)
+}
+
+func TestProcessTree(t *testing.T) {
+	_, ok := Which("sh")
+	if !ok {
+		t.Skip("sh not found")
+	}
+
+	// wait without operands exits 0, so exit non-zero in case the
+	// shell reaps the killed children before it is killed itself.
+	c := New("sh", "-c", "sleep 10 & sleep 10 & wait; exit 1")
+	assert.NoError(t, c.Start())
+
+	var children []int
+	for i := 0; i < 50; i++ {
+		children, _ = c.Children()
+		if len(children) == 2 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	assert.Len(t, children, 2)
+	info, err := c.ProcessInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, c.Pid(), info.Pid)
+
+	assert.NoError(t, c.SignalTree(syscall.SIGKILL))
+	assert.Error(t, c.Wait())
+	assert.NotNil(t, resourceUsage(c.ProcessState))
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package exec
+
+func listProcesses() (map[int]*ProcessInfo, error) {
+	return nil, ErrNotSupported
+}
+
+func readProcess(pid int) (*ProcessInfo, error) {
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,120 @@
+package exec
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// PtySize is the window size of a pseudo-terminal.
+type PtySize struct {
+	Rows uint16
+	Cols uint16
+}
+
+// DefaultPtySize is used when a pseudo-terminal is requested
+// without a size.
+var DefaultPtySize = PtySize{Rows: 24, Cols: 80}
+
+// WithPty runs the command on a pseudo-terminal so tools that check
+// for a terminal emit colors and progress output. Stdout and stderr
+// are both written to the terminal, so all output is reported as
+// stdout, with line endings translated to CRLF by the terminal.
+// Stdin is forwarded when it is set to a reader other than os.Stdin.
+// Wait copies output left by background descendants for at most the
+// grace period after the command exits, see WithGracePeriod.
+// Linux, macOS and FreeBSD are supported; Start returns
+// ErrNotSupported on other platforms.
+func (c *Cmd) WithPty(size *PtySize) *Cmd {
+	if size == nil {
+		s := DefaultPtySize
+		size = &s
+	}
+
+	c.pty = size
+	return c
+}
+
+// SetPtySize changes the window size of the pseudo-terminal of a
+// started command.
+func (c *Cmd) SetPtySize(size PtySize) error {
+	if c.ptmx == nil {
+		return ErrNotStarted
+	}
+
+	c.pty = &size
+	return setPtySize(c.ptmx, size)
+}
+
+// startPty starts the command attached to a new pseudo-terminal and
+// copies the terminal output to the command's stdout.
+func (c *Cmd) startPty() error {
+	ptmx, tty, err := openPty(*c.pty)
+	if err != nil {
+		return newStartError(c.Cmd.Path, err)
+	}
+
+	stdin := c.Cmd.Stdin
+	stdout := c.Cmd.Stdout
+	stderr := c.Cmd.Stderr
+	c.Cmd.Stdin = tty
+	c.Cmd.Stdout = tty
+	c.Cmd.Stderr = tty
+	setControllingTerminal(c.Cmd)
+	c.group = true
+
+	err = c.start()
+
+	// restore the configured stdio so the command can be reused.
+	c.Cmd.Stdin = stdin
+	c.Cmd.Stdout = stdout
+	c.Cmd.Stderr = stderr
+	_ = tty.Close()
+	if err != nil {
+		_ = ptmx.Close()
+		return err
+	}
+
+	c.ptmx = ptmx
+	c.ptyDone = make(chan struct{})
+	if stdin != nil && stdin != os.Stdin {
+		go func() {
+			_, _ = io.Copy(ptmx, stdin)
+		}()
+	}
+
+	go func() {
+		defer close(c.ptyDone)
+		if stdout == nil {
+			stdout = io.Discard
+		}
+
+		// reading the terminal fails with EIO once the command and its
+		// descendants have closed it, which marks the end of output.
+		_, _ = io.Copy(stdout, ptmx)
+	}()
+
+	return nil
+}
+
+// waitPty waits for the terminal output to be copied and closes it.
+// Descendants left running in the background keep the terminal open,
+// so the terminal is closed once the grace period has passed.
+func (c *Cmd) waitPty() {
+	if c.ptmx == nil {
+		return
+	}
+
+	t := time.NewTimer(c.grace())
+	defer t.Stop()
+	select {
+	case <-c.ptyDone:
+	case <-t.C:
+		// closing the terminal ends the pending read.
+		_ = c.ptmx.Close()
+		<-c.ptyDone
+	}
+
+	_ = c.ptmx.Close()
+	c.ptmx = nil
+}
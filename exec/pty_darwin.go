@@ -0,0 +1,55 @@
+//go:build darwin
+// +build darwin
+
+package exec
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// openPty opens a new pseudo-terminal pair through /dev/ptmx.
+func openPty(size PtySize) (*os.File, *os.File, error) {
+	ptmx, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := ioctl(ptmx, syscall.TIOCPTYGRANT, nil); err != nil {
+		_ = ptmx.Close()
+		return nil, nil, err
+	}
+
+	if err := ioctl(ptmx, syscall.TIOCPTYUNLK, nil); err != nil {
+		_ = ptmx.Close()
+		return nil, nil, err
+	}
+
+	// TIOCPTYGNAME fills a 128 byte buffer with the nul terminated
+	// name of the terminal.
+	name := make([]byte, 128)
+	if err := ioctl(ptmx, syscall.TIOCPTYGNAME, unsafe.Pointer(&name[0])); err != nil {
+		_ = ptmx.Close()
+		return nil, nil, err
+	}
+
+	if i := bytes.IndexByte(name, 0); i >= 0 {
+		name = name[:i]
+	}
+
+	tty, err := os.OpenFile(string(name), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		_ = ptmx.Close()
+		return nil, nil, err
+	}
+
+	if err := setPtySize(ptmx, size); err != nil {
+		_ = ptmx.Close()
+		_ = tty.Close()
+		return nil, nil, err
+	}
+
+	return ptmx, tty, nil
+}
@@ -0,0 +1,48 @@
+//go:build freebsd
+// +build freebsd
+
+package exec
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// openPty opens a new pseudo-terminal pair with posix_openpt. Granting
+// and unlocking the terminal are no-ops on FreeBSD.
+func openPty(size PtySize) (*os.File, *os.File, error) {
+	fd, _, errno := syscall.Syscall(syscall.SYS_POSIX_OPENPT, uintptr(syscall.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC), 0, 0)
+	if errno != 0 {
+		return nil, nil, errno
+	}
+
+	// a non-blocking descriptor is added to the poller, so closing it
+	// interrupts pending reads, see waitPty.
+	if err := syscall.SetNonblock(int(fd), true); err != nil {
+		_ = syscall.Close(int(fd))
+		return nil, nil, err
+	}
+
+	ptmx := os.NewFile(fd, "/dev/ptmx")
+	var n uint32
+	if err := ioctl(ptmx, syscall.TIOCGPTN, unsafe.Pointer(&n)); err != nil {
+		_ = ptmx.Close()
+		return nil, nil, err
+	}
+
+	tty, err := os.OpenFile("/dev/pts/"+strconv.Itoa(int(n)), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		_ = ptmx.Close()
+		return nil, nil, err
+	}
+
+	if err := setPtySize(ptmx, size); err != nil {
+		_ = ptmx.Close()
+		_ = tty.Close()
+		return nil, nil, err
+	}
+
+	return ptmx, tty, nil
+}
@@ -0,0 +1,19 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package exec
+
+import (
+	"os"
+	"os/exec"
+)
+
+func openPty(size PtySize) (*os.File, *os.File, error) {
+	return nil, nil, ErrNotSupported
+}
+
+func setPtySize(ptmx *os.File, size PtySize) error {
+	return ErrNotSupported
+}
+
+func setControllingTerminal(cmd *exec.Cmd) {}
@@ -0,0 +1,60 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package exec
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+// ioctl runs the request on the file without calling Fd, which would
+// put the file in blocking mode so that Close no longer interrupts a
+// pending read.
+func ioctl(f *os.File, req uintptr, arg unsafe.Pointer) error {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var errno syscall.Errno
+	err = rc.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+func setPtySize(ptmx *os.File, size PtySize) error {
+	ws := struct {
+		Rows   uint16
+		Cols   uint16
+		XPixel uint16
+		YPixel uint16
+	}{Rows: size.Rows, Cols: size.Cols}
+
+	return ioctl(ptmx, syscall.TIOCSWINSZ, unsafe.Pointer(&ws))
+}
+
+// setControllingTerminal starts the command in a new session with
+// its stdin, the terminal, as the controlling terminal.
+func setControllingTerminal(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Setpgid = false
+	cmd.SysProcAttr.Ctty = 0
+}
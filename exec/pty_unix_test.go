@@ -0,0 +1,57 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package exec_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyprxlabs/go/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPtyOutput(t *testing.T) {
+	_, ok := exec.Which("sh")
+	if !ok {
+		t.Skip("sh not found")
+	}
+
+	o, err := exec.New("sh", "-c", "if [ -t 1 ]; then echo tty; else echo notty; fi; echo err >&2").
+		WithPty(nil).
+		Output()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "tty\r\nerr\r\n", o.Text())
+	assert.Empty(t, o.Stderr)
+}
+
+func TestPtyBackgroundDescendant(t *testing.T) {
+	_, ok := exec.Which("sh")
+	if !ok {
+		t.Skip("sh not found")
+	}
+
+	// the descendant ignores the hangup and keeps the terminal open.
+	start := time.Now()
+	o, err := exec.New("sh", "-c", "trap '' HUP; sleep 5 & echo started").
+		WithPty(nil).
+		WithGracePeriod(200 * time.Millisecond).
+		Output()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "started\r\n", o.Text())
+	assert.Less(t, time.Since(start), 3*time.Second)
+}
+
+func TestPtySize(t *testing.T) {
+	_, ok := exec.Which("stty")
+	if !ok {
+		t.Skip("stty not found")
+	}
+
+	o, err := exec.New("stty", "size").WithPty(&exec.PtySize{Rows: 30, Cols: 100}).Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "30 100", strings.TrimSpace(o.Text()))
+}
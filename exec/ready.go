@@ -0,0 +1,374 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultProbeInterval is the time between two checks of a
+	// readiness probe.
+	DefaultProbeInterval = 50 * time.Millisecond
+
+	// readinessTailSize is the amount of output kept for errors.
+	readinessTailSize = 4096
+)
+
+// ReadinessProbe checks whether a started process is ready, e.g.
+// accepts connections.
+type ReadinessProbe interface {
+	// Ready reports whether the process is ready.
+	Ready(ctx context.Context) bool
+	// Timeout is how long to wait for the probe to succeed.
+	Timeout() time.Duration
+	String() string
+}
+
+// lineProbe is a probe that inspects the output of the process.
+type lineProbe interface {
+	observe(line string)
+	reset()
+}
+
+// invalidProbe is a probe that was created with invalid arguments.
+type invalidProbe interface {
+	invalid() error
+}
+
+// ReadinessError is returned by WaitReady when a probe did not succeed
+// before its timeout or the process exited first. Output holds the
+// tail of the process output.
+type ReadinessError struct {
+	Probe    string
+	Timeout  time.Duration
+	Exited   bool
+	ExitCode int
+	Output   string
+	Err      error
+}
+
+func (e *ReadinessError) Error() string {
+	msg := fmt.Sprintf("readiness probe %s timed out after %s", e.Probe, e.Timeout)
+	if e.Exited {
+		msg = fmt.Sprintf("readiness probe %s failed: process exited with code %d", e.Probe, e.ExitCode)
+	} else if e.Err != nil {
+		msg = fmt.Sprintf("readiness probe %s failed: %v", e.Probe, e.Err)
+	}
+
+	if e.Output != "" {
+		msg += "\noutput:\n" + e.Output
+	}
+
+	return msg
+}
+
+func (e *ReadinessError) Unwrap() error {
+	return e.Err
+}
+
+type readiness struct {
+	probes  []ReadinessProbe
+	mu      sync.Mutex
+	tail    []byte
+	streams []*stream
+	exited  chan struct{}
+	err     error
+}
+
+// WithReadiness attaches readiness probes to the command. After Start,
+// WaitReady waits for the probes to succeed in order. Output probes
+// observe the output from the moment the command starts.
+func (c *Cmd) WithReadiness(probes ...ReadinessProbe) *Cmd {
+	if c.ready == nil {
+		c.ready = &readiness{}
+	}
+
+	c.ready.probes = append(c.ready.probes, probes...)
+	return c
+}
+
+// WaitReady waits until all readiness probes succeed. It returns a
+// *ReadinessError when a probe is invalid, times out or the process
+// exits first, and ErrNotStarted when the command was not started.
+func (c *Cmd) WaitReady() error {
+	r := c.ready
+	if r == nil {
+		// without probes a started command is ready.
+		if c.Cmd.Process == nil {
+			return ErrNotStarted
+		}
+
+		return nil
+	}
+
+	if r.exited == nil {
+		return ErrNotStarted
+	}
+
+	parent := context.Background()
+	if c.ctx != nil {
+		parent = *c.ctx
+	}
+
+	for _, p := range r.probes {
+		if err := c.waitProbe(parent, p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Cmd) waitProbe(parent context.Context, p ReadinessProbe) error {
+	r := c.ready
+	if ip, ok := p.(invalidProbe); ok && ip.invalid() != nil {
+		return &ReadinessError{Probe: p.String(), Timeout: p.Timeout(), Err: ip.invalid()}
+	}
+	ctx, cancel := context.WithTimeout(parent, p.Timeout())
+	defer cancel()
+
+	t := time.NewTicker(DefaultProbeInterval)
+	defer t.Stop()
+	for {
+		if p.Ready(ctx) {
+			return nil
+		}
+
+		select {
+		case <-r.exited:
+			// the probe may have been satisfied right before the exit.
+			if p.Ready(ctx) {
+				return nil
+			}
+
+			return &ReadinessError{
+				Probe:    p.String(),
+				Timeout:  p.Timeout(),
+				Exited:   true,
+				ExitCode: exitCode(c.Cmd.ProcessState),
+				Output:   r.output(),
+				Err:      r.err,
+			}
+		case <-ctx.Done():
+			re := &ReadinessError{Probe: p.String(), Timeout: p.Timeout(), Output: r.output()}
+			if parent.Err() != nil {
+				re.Err = parent.Err()
+			}
+
+			return re
+		case <-t.C:
+		}
+	}
+}
+
+// tap routes the output through the readiness probes before the
+// command starts.
+func (r *readiness) tap(c *Cmd) {
+	r.mu.Lock()
+	r.tail = nil
+	r.mu.Unlock()
+	for _, p := range r.probes {
+		if lp, ok := p.(lineProbe); ok {
+			lp.reset()
+		}
+	}
+
+	r.exited = make(chan struct{})
+	stdout := newStream(r.observe, c.maxLineLength, c.Cmd.Stdout, r)
+	stderr := newStream(r.observe, c.maxLineLength, c.Cmd.Stderr, r)
+	r.streams = []*stream{stdout, stderr}
+	c.Cmd.Stdout = stdout.Writer()
+	c.Cmd.Stderr = stderr.Writer()
+}
+
+// watch waits for the started command in the background so probes
+// notice an early exit. Wait returns the stored result.
+func (r *readiness) watch(c *Cmd) {
+	exited := r.exited
+	streams := r.streams
+	go func() {
+		r.err = c.wait()
+		for _, s := range streams {
+			s.Close()
+		}
+
+		close(exited)
+	}()
+}
+
+func (r *readiness) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tail = append(r.tail, p...)
+	if len(r.tail) > readinessTailSize {
+		r.tail = append(r.tail[:0], r.tail[len(r.tail)-readinessTailSize:]...)
+	}
+
+	return len(p), nil
+}
+
+func (r *readiness) output() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return string(r.tail)
+}
+
+func (r *readiness) observe(line string) {
+	for _, p := range r.probes {
+		if lp, ok := p.(lineProbe); ok {
+			lp.observe(line)
+		}
+	}
+}
+
+type outputProbe struct {
+	pattern string
+	re      *regexp.Regexp
+	err     error
+	timeout time.Duration
+	matched int32
+}
+
+// OutputProbe succeeds once a line of stdout or stderr matches the
+// pattern. An invalid pattern is reported by WaitReady.
+func OutputProbe(pattern string, timeout time.Duration) ReadinessProbe {
+	re, err := regexp.Compile(pattern)
+	return &outputProbe{pattern: pattern, re: re, err: err, timeout: timeout}
+}
+
+func (p *outputProbe) invalid() error {
+	return p.err
+}
+
+func (p *outputProbe) observe(line string) {
+	if p.re != nil && p.re.MatchString(line) {
+		atomic.StoreInt32(&p.matched, 1)
+	}
+}
+
+func (p *outputProbe) reset() {
+	atomic.StoreInt32(&p.matched, 0)
+}
+
+func (p *outputProbe) Ready(ctx context.Context) bool {
+	return atomic.LoadInt32(&p.matched) == 1
+}
+
+func (p *outputProbe) Timeout() time.Duration {
+	return p.timeout
+}
+
+func (p *outputProbe) String() string {
+	return fmt.Sprintf("output %q", p.pattern)
+}
+
+type tcpProbe struct {
+	addr    string
+	timeout time.Duration
+}
+
+// TCPProbe succeeds once addr, e.g. "localhost:5432", accepts TCP
+// connections.
+func TCPProbe(addr string, timeout time.Duration) ReadinessProbe {
+	return &tcpProbe{addr: addr, timeout: timeout}
+}
+
+func (p *tcpProbe) Ready(ctx context.Context) bool {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		return false
+	}
+
+	_ = conn.Close()
+	return true
+}
+
+func (p *tcpProbe) Timeout() time.Duration {
+	return p.timeout
+}
+
+func (p *tcpProbe) String() string {
+	return "tcp " + p.addr
+}
+
+type fileProbe struct {
+	path    string
+	timeout time.Duration
+}
+
+// FileProbe succeeds once the file exists, e.g. a pid file or socket.
+func FileProbe(path string, timeout time.Duration) ReadinessProbe {
+	return &fileProbe{path: path, timeout: timeout}
+}
+
+func (p *fileProbe) Ready(ctx context.Context) bool {
+	_, err := os.Stat(p.path)
+	return err == nil
+}
+
+func (p *fileProbe) Timeout() time.Duration {
+	return p.timeout
+}
+
+func (p *fileProbe) String() string {
+	return "file " + p.path
+}
+
+type httpProbe struct {
+	url     string
+	err     error
+	timeout time.Duration
+}
+
+// HTTPProbe succeeds once a GET request to url returns a 2xx status.
+// A url that is not an absolute http or https url makes WaitReady
+// fail right away.
+func HTTPProbe(rawURL string, timeout time.Duration) ReadinessProbe {
+	p := &httpProbe{url: rawURL, timeout: timeout}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		p.err = err
+	} else if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		p.err = fmt.Errorf("invalid url %q: expected http://host or https://host", rawURL)
+	}
+
+	return p
+}
+
+func (p *httpProbe) invalid() error {
+	return p.err
+}
+
+func (p *httpProbe) Ready(ctx context.Context) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return false
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false
+	}
+
+	_, _ = io.Copy(io.Discard, res.Body)
+	_ = res.Body.Close()
+	return res.StatusCode >= 200 && res.StatusCode < 300
+}
+
+func (p *httpProbe) Timeout() time.Duration {
+	return p.timeout
+}
+
+func (p *httpProbe) String() string {
+	return "http " + p.url
+}
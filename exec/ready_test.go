@@ -0,0 +1,134 @@
+package exec_test
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hyprxlabs/go/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitReadyOutputAndFile(t *testing.T) {
+	_, ok := exec.Which("sh")
+	if !ok {
+		t.Skip("sh not found")
+	}
+
+	file := filepath.Join(t.TempDir(), "ready")
+	c := exec.New("sh", "-c", "sleep 0.1; echo 'listening on :8080'; touch "+file+"; exec sleep 10").
+		WithReadiness(
+			exec.OutputProbe(`listening on :\d+`, 5*time.Second),
+			exec.FileProbe(file, 5*time.Second),
+		)
+
+	assert.NoError(t, c.Start())
+	assert.NoError(t, c.WaitReady())
+	c.Stop()
+	assert.Error(t, c.Wait())
+}
+
+func TestWaitReadyEarlyExit(t *testing.T) {
+	_, ok := exec.Which("sh")
+	if !ok {
+		t.Skip("sh not found")
+	}
+
+	c := exec.New("sh", "-c", "echo 'starting'; echo 'bind: address in use' >&2; exit 2").
+		WithReadiness(exec.OutputProbe("ready", 5*time.Second))
+
+	assert.NoError(t, c.Start())
+	err := c.WaitReady()
+	var re *exec.ReadinessError
+	if assert.True(t, errors.As(err, &re)) {
+		assert.True(t, re.Exited)
+		assert.Equal(t, 2, re.ExitCode)
+		assert.Contains(t, re.Output, "bind: address in use")
+	}
+
+	var ee *exec.ExitError
+	assert.True(t, errors.As(c.Wait(), &ee))
+}
+
+func TestWaitReadyTimeoutTCPAndHTTP(t *testing.T) {
+	_, ok := exec.Which("sleep")
+	if !ok {
+		t.Skip("sleep not found")
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := l.Addr().String()
+	assert.NoError(t, l.Close())
+
+	c := exec.New("sleep", "10").WithReadiness(exec.TCPProbe(addr, 100*time.Millisecond))
+	assert.NoError(t, c.Start())
+	err = c.WaitReady()
+	var re *exec.ReadinessError
+	if assert.True(t, errors.As(err, &re)) {
+		assert.False(t, re.Exited)
+		assert.Contains(t, err.Error(), "timed out")
+	}
+
+	c.Stop()
+	_ = c.Wait()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c = exec.New("sleep", "10").WithReadiness(
+		exec.TCPProbe(srv.Listener.Addr().String(), time.Second),
+		exec.HTTPProbe(srv.URL, time.Second),
+	)
+
+	assert.NoError(t, c.Start())
+	assert.NoError(t, c.WaitReady())
+	c.Stop()
+	_ = c.Wait()
+}
+
+func TestWaitReadyInvalid(t *testing.T) {
+	c := exec.New("sleep", "10").WithReadiness(exec.OutputProbe("ready(", time.Second))
+	assert.True(t, errors.Is(c.WaitReady(), exec.ErrNotStarted))
+	assert.True(t, errors.Is(exec.New("sleep", "10").WaitReady(), exec.ErrNotStarted))
+
+	_, ok := exec.Which("sleep")
+	if !ok {
+		t.Skip("sleep not found")
+	}
+
+	if !assert.NoError(t, c.Start()) {
+		return
+	}
+
+	defer func() {
+		c.Stop()
+		_ = c.Wait()
+	}()
+
+	err := c.WaitReady()
+	var re *exec.ReadinessError
+	if assert.True(t, errors.As(err, &re)) {
+		assert.Contains(t, err.Error(), "missing closing )")
+	}
+
+	for _, url := range []string{"localhost:8080/health", "http://", "http://host:port/"} {
+		h := exec.New("sleep", "10").WithReadiness(exec.HTTPProbe(url, 10*time.Second))
+		if !assert.NoError(t, h.Start()) {
+			return
+		}
+
+		start := time.Now()
+		err = h.WaitReady()
+		assert.True(t, errors.As(err, &re), url)
+		assert.Less(t, time.Since(start), time.Second, url)
+		h.Stop()
+		_ = h.Wait()
+	}
+}
@@ -15,6 +15,32 @@ type Result struct {
 	Args      []string
 	StartedAt time.Time
 	EndedAt   time.Time
+	// TimedOut is true when the command was stopped because its
+	// timeout elapsed.
+	TimedOut bool
+	// Signal is the name of the signal that terminated the command,
+	// e.g. SIGTERM, or empty if the command exited on its own.
+	Signal string
+	// Usage holds the resources used by the command once it exited.
+	Usage *ResourceUsage
+	// DryRun is true when the command was not run because dry-run
+	// mode is enabled.
+	DryRun bool
+	// Attempts records every attempt when the command was run
+	// with a retry policy.
+	Attempts []Attempt
+	// StdoutTruncated and StderrTruncated are true when output was
+	// dropped because of the output limit.
+	StdoutTruncated bool
+	StderrTruncated bool
+	// StdoutSize and StderrSize are the number of bytes written to
+	// the streams, including dropped output.
+	StdoutSize int64
+	StderrSize int64
+	// StdoutFile and StderrFile are the paths of the files holding the
+	// full output when the output limit spills to files.
+	StdoutFile string
+	StderrFile string
 }
 
 func (o *Result) Text() string {
@@ -0,0 +1,160 @@
+package exec
+
+import (
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy describes how many times a command is attempted and
+// how long to wait between attempts.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the
+	// first one. Values less than 1 are treated as 1.
+	MaxAttempts int
+	// Delay is the wait before the second attempt.
+	Delay time.Duration
+	// MaxDelay caps the wait between attempts when greater than zero.
+	MaxDelay time.Duration
+	// Multiplier is applied to the delay after each attempt. Values
+	// less than or equal to 1 result in a fixed backoff.
+	Multiplier float64
+	// Jitter randomizes each delay by up to the given fraction,
+	// e.g. 0.2 results in a delay within +/- 20%.
+	Jitter float64
+	// Retryable decides if another attempt should be made. When nil,
+	// any attempt with a non-zero exit code is retried, except when the
+	// command could not be started or was not found.
+	Retryable func(o *Result) bool
+}
+
+// Attempt records the outcome of a single attempt of a command.
+type Attempt struct {
+	Code      int
+	StartedAt time.Time
+	EndedAt   time.Time
+	Err       error
+}
+
+// FixedBackoff returns a retry policy that waits the same delay
+// between each attempt.
+func FixedBackoff(maxAttempts int, delay time.Duration) *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: maxAttempts,
+		Delay:       delay,
+	}
+}
+
+// ExponentialBackoff returns a retry policy that doubles the delay
+// after each attempt up to maxDelay and applies 20% jitter.
+func ExponentialBackoff(maxAttempts int, delay, maxDelay time.Duration) *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: maxAttempts,
+		Delay:       delay,
+		MaxDelay:    maxDelay,
+		Multiplier:  2,
+		Jitter:      0.2,
+	}
+}
+
+// WithRetry sets the retry policy used by Run, Output, Quiet and
+// RunAndCapture. Stdin is only replayed between attempts when it
+// implements io.Seeker.
+func (c *Cmd) WithRetry(policy *RetryPolicy) *Cmd {
+	c.retry = policy
+	return c
+}
+
+// Backoff returns the delay to wait after the given attempt,
+// where the first attempt is 1.
+func (p *RetryPolicy) Backoff(attempt int) time.Duration {
+	d := float64(p.Delay)
+	if p.Multiplier > 1 && attempt > 1 {
+		d *= math.Pow(p.Multiplier, float64(attempt-1))
+	}
+
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (rand.Float64()*2 - 1)
+	}
+
+	if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+
+	if d < 0 {
+		return 0
+	}
+
+	return time.Duration(d)
+}
+
+func (p *RetryPolicy) retryable(o *Result, err error) bool {
+	if p.Retryable == nil {
+		var se *StartError
+		var nf *NotFoundError
+		if errors.As(err, &se) || errors.As(err, &nf) {
+			return false
+		}
+
+		return o.Code != 0
+	}
+
+	return p.Retryable(o)
+}
+
+func (c *Cmd) execRetry(mode runMode) (*Result, error) {
+	policy := c.retry
+	max := policy.MaxAttempts
+	if max < 1 {
+		max = 1
+	}
+
+	attempts := make([]Attempt, 0, max)
+	for i := 1; ; i++ {
+		if i > 1 {
+			c.reset()
+			if s, ok := c.Cmd.Stdin.(io.Seeker); ok {
+				_, _ = s.Seek(0, io.SeekStart)
+			}
+		}
+
+		out, err := c.execOnce(mode)
+		attempts = append(attempts, Attempt{
+			Code:      out.Code,
+			StartedAt: out.StartedAt,
+			EndedAt:   out.EndedAt,
+			Err:       err,
+		})
+
+		if i >= max || c.isStopped() || !policy.retryable(out, err) || !c.sleep(policy.Backoff(i)) {
+			out.Attempts = attempts
+			return out, err
+		}
+
+		removeSpillFiles(out)
+	}
+}
+
+// sleep waits for the duration and returns false if the command's
+// context is done first.
+func (c *Cmd) sleep(d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	if c.ctx == nil {
+		<-t.C
+		return true
+	}
+
+	select {
+	case <-t.C:
+		return true
+	case <-(*c.ctx).Done():
+		return false
+	}
+}
@@ -0,0 +1,65 @@
+package exec_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyprxlabs/go/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryRecordsAttempts(t *testing.T) {
+	_, ok := exec.Which("false")
+	if !ok {
+		t.Skip("false not found")
+	}
+
+	o, err := exec.New("false").WithRetry(exec.FixedBackoff(3, time.Millisecond)).Output()
+	assert.Error(t, err)
+	assert.NotNil(t, o)
+	assert.Len(t, o.Attempts, 3)
+	for _, a := range o.Attempts {
+		assert.NotEqual(t, 0, a.Code)
+		assert.False(t, a.StartedAt.IsZero())
+	}
+}
+
+func TestRetryStopsWhenNotRetryable(t *testing.T) {
+	_, ok := exec.Which("false")
+	if !ok {
+		t.Skip("false not found")
+	}
+
+	policy := exec.FixedBackoff(5, time.Millisecond)
+	policy.Retryable = func(o *exec.Result) bool {
+		return false
+	}
+
+	o, _ := exec.New("false").WithRetry(policy).Output()
+	assert.Len(t, o.Attempts, 1)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := exec.ExponentialBackoff(5, 10*time.Millisecond, 30*time.Millisecond)
+	p.Jitter = 0
+	assert.Equal(t, 10*time.Millisecond, p.Backoff(1))
+	assert.Equal(t, 20*time.Millisecond, p.Backoff(2))
+	assert.Equal(t, 30*time.Millisecond, p.Backoff(3))
+
+	// jitter never pushes the delay past the maximum.
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		assert.LessOrEqual(t, p.Backoff(4), 30*time.Millisecond)
+	}
+}
+
+func TestRetrySkipsStartErrors(t *testing.T) {
+	o, err := exec.New("definitely-not-a-real-command-xyz").
+		WithRetry(exec.FixedBackoff(3, 0)).
+		Output()
+
+	var nf *exec.NotFoundError
+	assert.True(t, errors.As(err, &nf))
+	assert.Len(t, o.Attempts, 1)
+}
@@ -0,0 +1,238 @@
+package exec
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Runner executes a command in place of spawning a process. It is
+// used to replace process execution in tests, see FakeRunner.
+//
+// The Result returned by RunCmd provides the command's stdout, stderr
+// and exit code. They are written to the configured stdio, line
+// callbacks and masker the same way the output of a real process is.
+// A non-zero exit code should be reported with an *ExitError.
+//
+// Runners replace Run, Output, Quiet and RunAndCapture. A command with
+// a runner cannot be started with Start, which is also used by
+// Interact and Supervisor, and returns ErrRunnerStart instead of
+// spawning a process.
+type Runner interface {
+	RunCmd(c *Cmd) (*Result, error)
+}
+
+var (
+	runner Runner
+
+	// ErrRunnerStart is returned by Start for a command with a runner.
+	ErrRunnerStart = errors.New("command with a runner cannot be started, use Run, Output, Quiet or RunAndCapture")
+)
+
+// SetRunner sets the runner used by all commands. Pass nil to spawn
+// processes again.
+func SetRunner(r Runner) {
+	runner = r
+}
+
+// WithRunner sets the runner used by this command.
+func (c *Cmd) WithRunner(r Runner) *Cmd {
+	c.runner = r
+	return c
+}
+
+func (c *Cmd) getRunner() Runner {
+	if c.runner != nil {
+		return c.runner
+	}
+
+	return runner
+}
+
+// runWith runs the command with the runner and writes the result's
+// output to the command's stdout and stderr.
+func (c *Cmd) runWith(r Runner) (*Result, error) {
+	c.rewindStdin()
+	restoreEnv := c.applyEnv()
+	defer restoreEnv()
+	c.logStart()
+	stdout, stderr := c.Cmd.Stdout, c.Cmd.Stderr
+	c.tapEvents()
+	c.maskOutput()
+
+	c.startedAt = time.Now()
+	startedAt := c.startedAt.UTC()
+	out, err := r.RunCmd(c)
+	if out == nil {
+		out = &Result{Code: -1}
+	}
+
+	c.emitStarted(nil)
+
+	if out.FileName == "" {
+		out.FileName = c.Cmd.Path
+		out.Args = c.Cmd.Args
+	}
+
+	if out.StartedAt.IsZero() {
+		out.StartedAt = startedAt
+		out.EndedAt = time.Now().UTC()
+	}
+
+	if c.Cmd.Stdout != nil {
+		_, _ = c.Cmd.Stdout.Write(out.Stdout)
+	}
+
+	if c.Cmd.Stderr != nil {
+		_, _ = c.Cmd.Stderr.Write(out.Stderr)
+	}
+
+	for _, w := range c.maskWriters {
+		_ = w.Flush()
+	}
+
+	c.maskWriters = nil
+	c.emitExited(out.Code, out.Signal, err)
+	c.Cmd.Stdout, c.Cmd.Stderr = stdout, stderr
+	return out, err
+}
+
+func (c *Cmd) execRunner(r Runner, mode runMode, stdout, stderr *stream, outb, errb *capture) (*Result, error) {
+	out, err := c.runWith(r)
+	stdout.Close()
+	stderr.Close()
+
+	out.Stdout = make([]byte, 0)
+	out.Stderr = make([]byte, 0)
+	if mode.captures() {
+		if ferr := fillOutput(out, outb, errb); ferr != nil && err == nil {
+			err = ferr
+		}
+	}
+
+	c.record(out, false)
+	var ee *ExitError
+	if err != nil && c.ignoreExitCode && errors.As(err, &ee) {
+		return out, nil
+	}
+
+	return out, err
+}
+
+// hasRunner reports whether any stage has a runner.
+func (p *Pipeline) hasRunner() bool {
+	for _, cmd := range p.cmds {
+		if cmd.getRunner() != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// execRunner runs the stages one after another with their runner,
+// feeding the stdout of each stage to the stdin of the next. Stages
+// without a runner run as a process.
+func (p *Pipeline) execRunner(mode runMode, res *PipelineResult) (*PipelineResult, error) {
+	n := len(p.cmds)
+	errs := make([]error, n)
+	outc, errcs, err := newPipelineCaptures(p.cmds, mode)
+	if err != nil {
+		return p.notStarted(res, err)
+	}
+
+	var stdin io.Reader
+	for i, cmd := range p.cmds {
+		// the stdout of a stage is kept in full as the next stage's
+		// stdin, only the last stage's stdout is captured.
+		var outb bytes.Buffer
+		if i > 0 {
+			cmd.Stdin = stdin
+		}
+
+		stdout := newStream(nil, p.maxLineLength, &outb)
+		if i == n-1 {
+			stdout = newStream(p.onStdoutLine, p.maxLineLength, outc)
+			if mode.inherits() {
+				stdout.Add(os.Stdout)
+			}
+		}
+
+		stderr := newStream(p.onStderrLine, p.maxLineLength, errcs[i])
+		if mode.inherits() {
+			stderr.Add(os.Stderr)
+		}
+
+		cmd.Stdout = stdout.Writer()
+		cmd.Stderr = stderr.Writer()
+		cmd.rawStdout = i < n-1
+		r := cmd.getRunner()
+		if r == nil {
+			r = processRunner{}
+		}
+
+		stage, err := cmd.runWith(r)
+		stdout.Close()
+		stderr.Close()
+
+		stage.Stdout = make([]byte, 0)
+		stage.Stderr = make([]byte, 0)
+		if mode.captures() {
+			stdoutc := &capture{head: make([]byte, 0)}
+			if i == n-1 {
+				stdoutc = outc
+			}
+
+			if ferr := fillOutput(stage, stdoutc, errcs[i]); ferr != nil && err == nil {
+				err = ferr
+			}
+		}
+
+		stdin = bytes.NewReader(outb.Bytes())
+		res.Stages[i] = stage
+		errs[i] = err
+		cmd.record(stage, false)
+	}
+
+	return p.finish(res, errs)
+}
+
+// processRunner runs the command as a process and waits for it. It
+// runs the stages without a runner when a pipeline runs its stages
+// one after another.
+type processRunner struct{}
+
+func (processRunner) RunCmd(c *Cmd) (*Result, error) {
+	if err := c.writeScript(); err != nil {
+		return nil, newStartError(c.Cmd.Path, err)
+	}
+
+	var outb, errb bytes.Buffer
+	stdout, stderr := c.Cmd.Stdout, c.Cmd.Stderr
+	c.Cmd.Stdout, c.Cmd.Stderr = &outb, &errb
+	err := c.Cmd.Run()
+	c.Cmd.Stdout, c.Cmd.Stderr = stdout, stderr
+
+	state := c.Cmd.ProcessState
+	out := &Result{
+		Stdout: outb.Bytes(),
+		Stderr: errb.Bytes(),
+		Code:   exitCode(state),
+		Signal: exitSignal(state),
+		Usage:  resourceUsage(state),
+	}
+
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		return out, &ExitError{FileName: c.Cmd.Path, Code: out.Code, Signal: out.Signal, Err: err}
+	}
+
+	if err != nil && state == nil {
+		return out, newStartError(c.Cmd.Path, err)
+	}
+
+	return out, err
+}
@@ -0,0 +1,214 @@
+package exec
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type scriptShell struct {
+	exe     []string
+	ext     string
+	shebang bool
+	args    func(file string) []string
+}
+
+var scriptShells = map[string]scriptShell{
+	"bash": {
+		exe:     []string{"bash"},
+		ext:     ".sh",
+		shebang: true,
+		args: func(file string) []string {
+			return []string{"--noprofile", "--norc", "-e", "-o", "pipefail", file}
+		},
+	},
+	"sh": {
+		exe:     []string{"sh"},
+		ext:     ".sh",
+		shebang: true,
+		args: func(file string) []string {
+			return []string{"-e", file}
+		},
+	},
+	"pwsh": {
+		exe: []string{"pwsh"},
+		ext: ".ps1",
+		args: func(file string) []string {
+			return []string{"-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-File", file}
+		},
+	},
+	"powershell": {
+		exe: []string{"powershell"},
+		ext: ".ps1",
+		args: func(file string) []string {
+			return []string{"-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-File", file}
+		},
+	},
+	"python": {
+		exe:     []string{"python3", "python"},
+		ext:     ".py",
+		shebang: true,
+		args: func(file string) []string {
+			return []string{file}
+		},
+	},
+	"node": {
+		exe:     []string{"node"},
+		ext:     ".js",
+		shebang: true,
+		args: func(file string) []string {
+			return []string{file}
+		},
+	},
+	"deno": {
+		exe: []string{"deno"},
+		ext: ".ts",
+		args: func(file string) []string {
+			return []string{"run", "-A", file}
+		},
+	},
+	"cmd": {
+		exe: []string{"cmd"},
+		ext: ".cmd",
+		args: func(file string) []string {
+			return []string{"/D", "/E:ON", "/V:OFF", "/S", "/C", "CALL", file}
+		},
+	},
+}
+
+// Script creates a command that writes body to a temporary file and
+// runs it with the given shell. Supported shells are bash, sh, pwsh,
+// powershell, python, node, deno and cmd. The interpreter is resolved
+// with Find and then the PATH. The temporary file is written when the
+// command starts and removed after Run, Output, Quiet or RunAndCapture
+// returns, or after Wait when the command is started manually. A
+// command that is never run leaves no file behind.
+func Script(shell, body string) (*Cmd, error) {
+	return script(nil, shell, body)
+}
+
+// ScriptContext is like Script but creates the command with the
+// provided context.
+func ScriptContext(ctx context.Context, shell, body string) (*Cmd, error) {
+	return script(ctx, shell, body)
+}
+
+// RunScript runs the script with stdio inherited from the current process.
+func RunScript(shell, body string) (*Result, error) {
+	c, err := Script(shell, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Run()
+}
+
+// OutputScript runs the script and captures its output.
+func OutputScript(shell, body string) (*Result, error) {
+	c, err := Script(shell, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Output()
+}
+
+func script(ctx context.Context, shell, body string) (*Cmd, error) {
+	s, ok := scriptShells[strings.ToLower(shell)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported script shell: %s", shell)
+	}
+
+	exe := ""
+	for _, name := range s.exe {
+		if path, err := Find(name, nil); err == nil {
+			exe = path
+			break
+		}
+
+		if path, ok := Which(name); ok {
+			exe = path
+			break
+		}
+	}
+
+	if exe == "" {
+		return nil, &NotFoundError{Name: s.exe[0]}
+	}
+
+	if s.shebang && !strings.HasPrefix(body, "#!") {
+		body = "#!/usr/bin/env " + s.exe[0] + "\n" + body
+	}
+
+	if s.ext == ".cmd" {
+		body = strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n")
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	file := &scriptFile{
+		path: filepath.Join(os.TempDir(), "script-"+hex.EncodeToString(b)+s.ext),
+		body: body,
+	}
+
+	var c *Cmd
+	if ctx != nil {
+		c = NewContext(ctx, exe, s.args(file.path)...)
+	} else {
+		c = New(exe, s.args(file.path)...)
+	}
+
+	c.script = file
+	return c, nil
+}
+
+// scriptFile is the temporary file a script is written to when the
+// command starts.
+type scriptFile struct {
+	path    string
+	body    string
+	written bool
+}
+
+// writeScript writes the script file before the command starts and
+// registers its removal as cleanup.
+func (c *Cmd) writeScript() error {
+	s := c.script
+	if s == nil || s.written {
+		return nil
+	}
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o700)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.WriteString(s.body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err == nil {
+		err = os.Chmod(s.path, 0o700)
+	}
+
+	if err != nil {
+		_ = os.Remove(s.path)
+		return err
+	}
+
+	s.written = true
+	c.cleanup = append(c.cleanup, func() {
+		_ = os.Remove(s.path)
+		s.written = false
+	})
+
+	return nil
+}
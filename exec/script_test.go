@@ -0,0 +1,39 @@
+package exec_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hyprxlabs/go/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScriptBash(t *testing.T) {
+	_, ok := exec.Which("bash")
+	if !ok {
+		t.Skip("bash not found")
+	}
+
+	cmd, err := exec.Script("bash", "echo one\nfalse\necho two\n")
+	assert.NoError(t, err)
+	file := cmd.Args[len(cmd.Args)-1]
+	assert.True(t, strings.HasSuffix(file, ".sh"))
+
+	// the file is only written when the command starts.
+	_, err = os.Stat(file)
+	assert.True(t, os.IsNotExist(err))
+
+	o, err := cmd.Output()
+	assert.Error(t, err)
+	assert.Equal(t, 1, o.Code)
+	assert.Equal(t, "one\n", o.Text())
+
+	_, err = os.Stat(file)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestScriptUnsupportedShell(t *testing.T) {
+	_, err := exec.Script("fish-nope", "echo hi")
+	assert.Error(t, err)
+}
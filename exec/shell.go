@@ -0,0 +1,568 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ShellCommand is a command line parsed by ParseShell. It supports a
+// portable subset of POSIX shell syntax that is executed in-process
+// without spawning bash or cmd.exe:
+//
+//	a | b        pipe stdout of a into b
+//	a && b       run b when a succeeds
+//	a || b       run b when a fails
+//	a ; b        run a then b, newlines are treated the same way
+//	             except after &&, || and |, which continue the command
+//	a > f        write stdout to f, 2> writes stderr
+//	a >> f       append stdout to f
+//	a < f        read stdin from f
+//	a 2>&1       send stderr to where stdout currently goes
+//
+// Variables, globbing, subshells and background jobs are not supported.
+// A backslash only escapes quotes, whitespace and operator characters so
+// that Windows paths can be used without quoting.
+type ShellCommand struct {
+	lists     []shellList
+	cwd       string
+	ctx       context.Context
+	configure func(c *Cmd)
+}
+
+type shellList struct {
+	pipelines []shellPipeline
+	ops       []string // "&&" or "||" between pipelines
+}
+
+type shellPipeline struct {
+	cmds []shellCmd
+}
+
+type shellCmd struct {
+	args      []string
+	redirects []shellRedirect
+}
+
+type shellRedirect struct {
+	fd     int
+	op     string // "<", ">", ">>" or ">&"
+	path   string
+	target int
+}
+
+// ParseShell parses a command line into a ShellCommand.
+func ParseShell(line string) (*ShellCommand, error) {
+	tokens, err := tokenizeShell(line)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &shellParser{tokens: tokens}
+	lists, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ShellCommand{lists: lists}, nil
+}
+
+// RunShell parses and runs the command line with stdio inherited
+// from the current process.
+func RunShell(line string) (*Result, error) {
+	s, err := ParseShell(line)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Run()
+}
+
+// OutputShell parses and runs the command line and captures the
+// output that is not redirected to files.
+func OutputShell(line string) (*Result, error) {
+	s, err := ParseShell(line)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Output()
+}
+
+// WithCwd sets the working directory for every command. Relative
+// redirection paths are resolved against it.
+func (s *ShellCommand) WithCwd(dir string) *ShellCommand {
+	s.cwd = dir
+	return s
+}
+
+// WithContext sets the context used to create every command.
+func (s *ShellCommand) WithContext(ctx context.Context) *ShellCommand {
+	s.ctx = ctx
+	return s
+}
+
+// Configure registers a function that is called for each command
+// before it runs, e.g. to set the environment or a masker.
+func (s *ShellCommand) Configure(f func(c *Cmd)) *ShellCommand {
+	s.configure = f
+	return s
+}
+
+func (s *ShellCommand) Run() (*Result, error) {
+	return s.exec(modeInherit)
+}
+
+func (s *ShellCommand) Output() (*Result, error) {
+	return s.exec(modeCapture)
+}
+
+func (s *ShellCommand) Quiet() (*Result, error) {
+	return s.exec(modeQuiet)
+}
+
+func (s *ShellCommand) RunAndCapture() (*Result, error) {
+	return s.exec(modeTee)
+}
+
+func (s *ShellCommand) exec(mode runMode) (*Result, error) {
+	out := &Result{Stdout: make([]byte, 0), Stderr: make([]byte, 0)}
+	out.StartedAt = time.Now().UTC()
+
+	var err error
+	for _, list := range s.lists {
+		for i, pl := range list.pipelines {
+			if i > 0 {
+				op := list.ops[i-1]
+				if (op == "&&" && out.Code != 0) || (op == "||" && out.Code == 0) {
+					continue
+				}
+			}
+
+			var r *Result
+			r, err = s.runPipeline(pl, mode)
+			out.FileName = r.FileName
+			out.Args = r.Args
+			out.Code = r.Code
+			out.Signal = r.Signal
+			out.TimedOut = r.TimedOut
+			out.Stdout = append(out.Stdout, r.Stdout...)
+			out.Stderr = append(out.Stderr, r.Stderr...)
+		}
+	}
+
+	out.EndedAt = time.Now().UTC()
+	if out.Code == 0 {
+		return out, nil
+	}
+
+	return out, err
+}
+
+func (s *ShellCommand) runPipeline(pl shellPipeline, mode runMode) (*Result, error) {
+	files := make([]*os.File, 0)
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+
+	p := &Pipeline{}
+	for _, sc := range pl.cmds {
+		var cmd *Cmd
+		if s.ctx != nil {
+			cmd = NewContext(s.ctx, sc.args[0], sc.args[1:]...)
+		} else {
+			cmd = New(sc.args[0], sc.args[1:]...)
+		}
+
+		cmd.Dir = s.cwd
+		if s.configure != nil {
+			s.configure(cmd)
+		}
+
+		stdio, opened, err := s.redirect(sc)
+		files = append(files, opened...)
+		if err != nil {
+			now := time.Now().UTC()
+			r := &Result{FileName: cmd.Path, Args: cmd.Args, Code: 1, StartedAt: now, EndedAt: now}
+			return r, err
+		}
+
+		cmd.stdio = stdio
+		p.cmds = append(p.cmds, cmd)
+	}
+
+	res, err := p.exec(mode)
+	out := *res.Result
+	out.Stderr = make([]byte, 0)
+	for _, stage := range res.Stages {
+		out.Stderr = append(out.Stderr, stage.Stderr...)
+	}
+
+	return &out, err
+}
+
+// redirect opens the files used by the redirections of the command
+// and returns a function that applies them to the default stdio.
+func (s *ShellCommand) redirect(sc shellCmd) (stdioFunc, []*os.File, error) {
+	if len(sc.redirects) == 0 {
+		return nil, nil, nil
+	}
+
+	files := make([]*os.File, len(sc.redirects))
+	opened := make([]*os.File, 0, len(sc.redirects))
+	for i, r := range sc.redirects {
+		if r.op == ">&" {
+			continue
+		}
+
+		path := r.path
+		if !filepath.IsAbs(path) && s.cwd != "" {
+			path = filepath.Join(s.cwd, path)
+		}
+
+		var f *os.File
+		var err error
+		switch r.op {
+		case "<":
+			f, err = os.Open(path)
+		case ">":
+			f, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o666)
+		case ">>":
+			f, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o666)
+		}
+
+		if err != nil {
+			return nil, opened, err
+		}
+
+		files[i] = f
+		opened = append(opened, f)
+	}
+
+	redirects := sc.redirects
+	apply := func(stdin io.Reader, stdout, stderr io.Writer) (io.Reader, io.Writer, io.Writer) {
+		fds := map[int]io.Writer{1: stdout, 2: stderr}
+		for i, r := range redirects {
+			switch r.op {
+			case "<":
+				stdin = files[i]
+			case ">", ">>":
+				fds[r.fd] = files[i]
+			case ">&":
+				fds[r.fd] = fds[r.target]
+			}
+		}
+
+		return stdin, fds[1], fds[2]
+	}
+
+	return apply, opened, nil
+}
+
+type shellTokenKind int
+
+const (
+	tokenWord shellTokenKind = iota
+	tokenOperator
+	tokenRedirect
+)
+
+type shellToken struct {
+	kind   shellTokenKind
+	value  string
+	fd     int
+	target int
+}
+
+func isShellOperator(r rune) bool {
+	return r == '|' || r == '&' || r == ';' || r == '<' || r == '>'
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return len(s) > 0
+}
+
+func tokenizeShell(line string) ([]shellToken, error) {
+	tokens := make([]shellToken, 0)
+	runes := []rune(line)
+	l := len(runes)
+	sb := strings.Builder{}
+	inWord := false
+	quoted := false
+
+	flush := func() {
+		if inWord {
+			tokens = append(tokens, shellToken{kind: tokenWord, value: sb.String()})
+		}
+
+		sb.Reset()
+		inWord = false
+		quoted = false
+	}
+
+	for i := 0; i < l; i++ {
+		c := runes[i]
+		switch {
+		case c == '\'':
+			end := -1
+			for j := i + 1; j < l; j++ {
+				if runes[j] == '\'' {
+					end = j
+					break
+				}
+			}
+
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+
+			sb.WriteString(string(runes[i+1 : end]))
+			inWord = true
+			quoted = true
+			i = end
+
+		case c == '"':
+			closed := false
+			for i++; i < l; i++ {
+				d := runes[i]
+				if d == '\\' && i+1 < l && (runes[i+1] == '"' || runes[i+1] == '\\') {
+					i++
+					sb.WriteRune(runes[i])
+					continue
+				}
+
+				if d == '"' {
+					closed = true
+					break
+				}
+
+				sb.WriteRune(d)
+			}
+
+			if !closed {
+				return nil, errors.New("unterminated double quote")
+			}
+
+			inWord = true
+			quoted = true
+
+		case c == '\\' && i+1 < l && (runes[i+1] == '\n' || runes[i+1] == '\r'):
+			// line continuation
+			i++
+			if runes[i] == '\r' && i+1 < l && runes[i+1] == '\n' {
+				i++
+			}
+
+		case c == '\\' && i+1 < l && (unicode.IsSpace(runes[i+1]) || isShellOperator(runes[i+1]) || runes[i+1] == '\'' || runes[i+1] == '"'):
+			i++
+			sb.WriteRune(runes[i])
+			inWord = true
+
+		case c == '#' && !inWord:
+			for i < l && runes[i] != '\n' {
+				i++
+			}
+			i--
+
+		case c == '\n':
+			flush()
+			// a command continues on the next line after &&, || and |.
+			if n := len(tokens); n > 0 && tokens[n-1].kind == tokenOperator {
+				if v := tokens[n-1].value; v == "&&" || v == "||" || v == "|" {
+					continue
+				}
+			}
+
+			tokens = append(tokens, shellToken{kind: tokenOperator, value: ";"})
+
+		case unicode.IsSpace(c):
+			flush()
+
+		case c == '|' || c == '&':
+			flush()
+			if i+1 < l && runes[i+1] == c {
+				i++
+				tokens = append(tokens, shellToken{kind: tokenOperator, value: string([]rune{c, c})})
+				continue
+			}
+
+			if c == '&' {
+				return nil, errors.New("background jobs (&) are not supported")
+			}
+
+			tokens = append(tokens, shellToken{kind: tokenOperator, value: "|"})
+
+		case c == ';':
+			flush()
+			tokens = append(tokens, shellToken{kind: tokenOperator, value: ";"})
+
+		case c == '<' || c == '>':
+			fd := 0
+			if c == '>' {
+				fd = 1
+			}
+
+			// a word made only of digits right before the operator is
+			// the file descriptor, e.g. 2>.
+			if inWord && !quoted && isDigits(sb.String()) {
+				fd, _ = strconv.Atoi(sb.String())
+				sb.Reset()
+				inWord = false
+			}
+
+			flush()
+			t := shellToken{kind: tokenRedirect, value: string(c), fd: fd}
+			if c == '>' && i+1 < l && runes[i+1] == '>' {
+				i++
+				t.value = ">>"
+			} else if c == '>' && i+1 < l && runes[i+1] == '&' {
+				i++
+				j := i + 1
+				for j < l && unicode.IsDigit(runes[j]) {
+					j++
+				}
+
+				if j == i+1 {
+					return nil, errors.New("expected file descriptor after >&")
+				}
+
+				t.value = ">&"
+				t.target, _ = strconv.Atoi(string(runes[i+1 : j]))
+				i = j - 1
+			}
+
+			if (t.value == "<" && fd != 0) || (t.value != "<" && fd != 1 && fd != 2) || (t.value == ">&" && t.target != 1 && t.target != 2) {
+				return nil, fmt.Errorf("unsupported file descriptor in redirection %d%s", fd, t.value)
+			}
+
+			tokens = append(tokens, t)
+
+		default:
+			sb.WriteRune(c)
+			inWord = true
+		}
+	}
+
+	flush()
+	return tokens, nil
+}
+
+type shellParser struct {
+	tokens []shellToken
+	pos    int
+}
+
+func (p *shellParser) peek() *shellToken {
+	if p.pos >= len(p.tokens) {
+		return nil
+	}
+
+	return &p.tokens[p.pos]
+}
+
+func (p *shellParser) parse() ([]shellList, error) {
+	lists := make([]shellList, 0)
+	for {
+		// skip empty statements
+		for t := p.peek(); t != nil && t.kind == tokenOperator && t.value == ";"; t = p.peek() {
+			p.pos++
+		}
+
+		if p.peek() == nil {
+			return lists, nil
+		}
+
+		list, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+
+		lists = append(lists, list)
+	}
+}
+
+func (p *shellParser) parseList() (shellList, error) {
+	list := shellList{}
+	for {
+		pl, err := p.parsePipeline()
+		if err != nil {
+			return list, err
+		}
+
+		list.pipelines = append(list.pipelines, pl)
+		t := p.peek()
+		if t == nil || t.kind != tokenOperator || (t.value != "&&" && t.value != "||") {
+			return list, nil
+		}
+
+		list.ops = append(list.ops, t.value)
+		p.pos++
+	}
+}
+
+func (p *shellParser) parsePipeline() (shellPipeline, error) {
+	pl := shellPipeline{}
+	for {
+		cmd, err := p.parseCommand()
+		if err != nil {
+			return pl, err
+		}
+
+		pl.cmds = append(pl.cmds, cmd)
+		t := p.peek()
+		if t == nil || t.kind != tokenOperator || t.value != "|" {
+			return pl, nil
+		}
+
+		p.pos++
+	}
+}
+
+func (p *shellParser) parseCommand() (shellCmd, error) {
+	cmd := shellCmd{}
+	for t := p.peek(); t != nil && t.kind != tokenOperator; t = p.peek() {
+		p.pos++
+		if t.kind == tokenWord {
+			cmd.args = append(cmd.args, t.value)
+			continue
+		}
+
+		r := shellRedirect{fd: t.fd, op: t.value, target: t.target}
+		if r.op != ">&" {
+			next := p.peek()
+			if next == nil || next.kind != tokenWord {
+				return cmd, fmt.Errorf("syntax error: expected file name after %s", r.op)
+			}
+
+			r.path = next.value
+			p.pos++
+		}
+
+		cmd.redirects = append(cmd.redirects, r)
+	}
+
+	if len(cmd.args) == 0 {
+		if t := p.peek(); t != nil {
+			return cmd, fmt.Errorf("syntax error near unexpected token '%s'", t.value)
+		}
+
+		return cmd, errors.New("syntax error: expected command")
+	}
+
+	return cmd, nil
+}
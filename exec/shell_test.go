@@ -0,0 +1,65 @@
+package exec
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseShell(t *testing.T) {
+	s, err := ParseShell(`make build > "out file.log" 2>&1 && echo 'ok done' | tr a-z A-Z; echo C:\tools\bin`)
+	assert.NoError(t, err)
+	assert.Len(t, s.lists, 2)
+
+	first := s.lists[0]
+	assert.Equal(t, []string{"&&"}, first.ops)
+	assert.Equal(t, []string{"make", "build"}, first.pipelines[0].cmds[0].args)
+	assert.Equal(t, []shellRedirect{
+		{fd: 1, op: ">", path: "out file.log"},
+		{fd: 2, op: ">&", target: 1},
+	}, first.pipelines[0].cmds[0].redirects)
+	assert.Len(t, first.pipelines[1].cmds, 2)
+	assert.Equal(t, []string{"echo", "ok done"}, first.pipelines[1].cmds[0].args)
+
+	assert.Equal(t, []string{"echo", `C:\tools\bin`}, s.lists[1].pipelines[0].cmds[0].args)
+}
+
+func TestParseShellContinuation(t *testing.T) {
+	s, err := ParseShell("make build &&\n\n  echo ok ||\r\n echo failed |\n tr a-z A-Z\necho next")
+	assert.NoError(t, err)
+	assert.Len(t, s.lists, 2)
+	assert.Equal(t, []string{"&&", "||"}, s.lists[0].ops)
+	assert.Len(t, s.lists[0].pipelines[2].cmds, 2)
+	assert.Equal(t, []string{"echo", "next"}, s.lists[1].pipelines[0].cmds[0].args)
+}
+
+func TestParseShellErrors(t *testing.T) {
+	for _, line := range []string{"| grep x", "echo a &&", "echo a &&\n", "echo a && ; echo b", "echo 'open", "sleep 1 &", "echo >", "cat 3< file"} {
+		_, err := ParseShell(line)
+		assert.Error(t, err, line)
+	}
+}
+
+func TestShellRedirectionsAndOperators(t *testing.T) {
+	_, hasSh := Which("sh")
+	_, hasCat := Which("cat")
+	if !hasSh || !hasCat {
+		t.Skip("sh or cat not found")
+	}
+
+	dir := t.TempDir()
+	s, err := ParseShell(`sh -c "echo out; echo err >&2" > log.txt 2>&1 && cat < log.txt; sh -c "exit 3" || echo recovered >> log.txt`)
+	assert.NoError(t, err)
+
+	o, err := s.WithCwd(dir).Output()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, o.Code)
+	assert.Equal(t, "out\nerr\n", o.Text())
+
+	data, err := os.ReadFile(filepath.Join(dir, "log.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "out\nerr\nrecovered", strings.TrimSpace(string(data)))
+}
@@ -0,0 +1,60 @@
+//go:build go1.21
+// +build go1.21
+
+package exec
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogHandler returns an EventHandler that writes command events to
+// the logger. Start and exit events are logged at info level, output
+// at debug level and errors or non-zero exits at error and warn level.
+//
+//	exec.SetEventHandler(exec.SlogHandler(slog.Default()))
+func SlogHandler(logger *slog.Logger) EventHandler {
+	return func(e Event) {
+		attrs := []slog.Attr{
+			slog.String("path", e.Path),
+			slog.Any("args", e.Args),
+			slog.Int("pid", e.Pid),
+		}
+
+		if e.Dir != "" {
+			attrs = append(attrs, slog.String("dir", e.Dir))
+		}
+
+		level := slog.LevelInfo
+		msg := "command started"
+		switch e.Type {
+		case EVENT_OUTPUT:
+			level = slog.LevelDebug
+			msg = "command output"
+			attrs = append(attrs, slog.String("stream", e.Stream), slog.String("data", string(e.Data)))
+		case EVENT_EXIT:
+			msg = "command exited"
+			if e.Code != 0 {
+				level = slog.LevelWarn
+			}
+
+			attrs = append(attrs, slog.Int("code", e.Code), slog.Duration("duration", e.Duration))
+			if e.Signal != "" {
+				attrs = append(attrs, slog.String("signal", e.Signal))
+			}
+		case EVENT_ERROR:
+			level = slog.LevelError
+			msg = "command failed"
+			attrs = append(attrs, slog.Int("code", e.Code))
+			if e.Duration > 0 {
+				attrs = append(attrs, slog.Duration("duration", e.Duration))
+			}
+		}
+
+		if e.Err != nil {
+			attrs = append(attrs, slog.String("error", e.Err.Error()))
+		}
+
+		logger.LogAttrs(context.Background(), level, msg, attrs...)
+	}
+}
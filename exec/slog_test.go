@@ -0,0 +1,33 @@
+//go:build go1.21
+// +build go1.21
+
+package exec_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/hyprxlabs/go/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	fake := exec.NewFakeRunner()
+	fake.Expect("git", "status").Stdout("clean\n")
+
+	_, err := exec.New("git", "status").WithRunner(fake).OnEvent(exec.SlogHandler(logger)).Output()
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 3)
+	var exit map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[2]), &exit))
+	assert.Equal(t, "command exited", exit["msg"])
+	assert.Equal(t, "INFO", exit["level"])
+	assert.Equal(t, float64(0), exit["code"])
+}
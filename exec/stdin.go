@@ -0,0 +1,83 @@
+package exec
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+)
+
+// WithStdinString feeds the string to the command's stdin.
+func (c *Cmd) WithStdinString(s string) *Cmd {
+	c.Cmd.Stdin = strings.NewReader(s)
+	return c
+}
+
+// WithStdinBytes feeds the bytes to the command's stdin.
+func (c *Cmd) WithStdinBytes(b []byte) *Cmd {
+	c.Cmd.Stdin = bytes.NewReader(b)
+	return c
+}
+
+// WithStdinFile feeds the file to the command's stdin. The file is
+// opened when the command starts reading and closed once it has been
+// read or the command exits. Each run reads the file from the start.
+// An error opening the file is returned by Wait.
+func (c *Cmd) WithStdinFile(path string) *Cmd {
+	c.Cmd.Stdin = &fileReader{path: path}
+	return c
+}
+
+// rewindStdin prepares a file set with WithStdinFile to be read again
+// by the next run.
+func (c *Cmd) rewindStdin() {
+	r, ok := c.Cmd.Stdin.(*fileReader)
+	if !ok {
+		return
+	}
+
+	r.close()
+	r.err = nil
+	if !r.registered {
+		r.registered = true
+		c.cleanup = append(c.cleanup, func() {
+			r.close()
+			r.registered = false
+		})
+	}
+}
+
+type fileReader struct {
+	path       string
+	f          *os.File
+	err        error
+	registered bool // the close is registered as cleanup
+}
+
+func (r *fileReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	if r.f == nil {
+		r.f, r.err = os.Open(r.path)
+		if r.err != nil {
+			return 0, r.err
+		}
+	}
+
+	n, err := r.f.Read(p)
+	if err == io.EOF {
+		r.close()
+		r.err = io.EOF
+	}
+
+	return n, err
+}
+
+func (r *fileReader) close() {
+	if r.f != nil {
+		_ = r.f.Close()
+		r.f = nil
+	}
+}
@@ -0,0 +1,143 @@
+package exec
+
+import (
+	"bytes"
+	"io"
+)
+
+const (
+	// DefaultMaxLineLength is the maximum number of bytes buffered for a single
+	// line before it is handed to a line callback. Longer lines are split
+	// into multiple callbacks so memory use stays bounded.
+	DefaultMaxLineLength = 64 * 1024
+)
+
+// lineWriter is an io.Writer that buffers partial writes and invokes
+// a callback once per complete line. Trailing "\r" characters are
+// removed so CRLF output produces the same lines as LF output.
+type lineWriter struct {
+	buf      []byte
+	max      int
+	callback func(line string)
+}
+
+func newLineWriter(max int, callback func(line string)) *lineWriter {
+	if max <= 0 {
+		max = DefaultMaxLineLength
+	}
+
+	return &lineWriter{
+		buf:      make([]byte, 0, 256),
+		max:      max,
+		callback: callback,
+	}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.append(p)
+			break
+		}
+
+		w.append(p[:i])
+		w.emit(true)
+		p = p[i+1:]
+	}
+
+	return n, nil
+}
+
+// append adds the chunk to the pending line and emits max sized
+// segments when the line grows beyond the configured limit.
+func (w *lineWriter) append(p []byte) {
+	for len(w.buf)+len(p) > w.max {
+		take := w.max - len(w.buf)
+		w.buf = append(w.buf, p[:take]...)
+		p = p[take:]
+		w.emit(false)
+	}
+
+	w.buf = append(w.buf, p...)
+}
+
+func (w *lineWriter) emit(eol bool) {
+	line := w.buf
+	if eol && len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+
+	w.callback(string(line))
+	w.buf = w.buf[:0]
+}
+
+// Flush emits any buffered partial line.
+func (w *lineWriter) Flush() {
+	if len(w.buf) == 0 {
+		return
+	}
+
+	w.emit(true)
+}
+
+// stream describes the destinations for a single output stream
+// of a command, i.e. stdout or stderr.
+type stream struct {
+	writers []io.Writer
+	lines   *lineWriter
+}
+
+func newStream(onLine func(line string), maxLine int, writers ...io.Writer) *stream {
+	s := &stream{}
+	for _, w := range writers {
+		if w != nil {
+			s.writers = append(s.writers, w)
+		}
+	}
+
+	if onLine != nil {
+		s.lines = newLineWriter(maxLine, onLine)
+		s.writers = append(s.writers, s.lines)
+	}
+
+	return s
+}
+
+// Add appends a destination for the stream. Writers are invoked
+// before the line callback so captured output is never behind
+// what callbacks have observed.
+func (s *stream) Add(w io.Writer) {
+	if w == nil {
+		return
+	}
+
+	if s.lines == nil {
+		s.writers = append(s.writers, w)
+		return
+	}
+
+	n := len(s.writers) - 1
+	s.writers = append(s.writers[:n], w, s.lines)
+}
+
+// Writer returns the io.Writer that should be assigned to the
+// underlying os/exec command, or nil when the stream is discarded.
+func (s *stream) Writer() io.Writer {
+	switch len(s.writers) {
+	case 0:
+		return nil
+	case 1:
+		return s.writers[0]
+	default:
+		return io.MultiWriter(s.writers...)
+	}
+}
+
+// Close flushes any partial line that is still buffered.
+func (s *stream) Close() {
+	if s.lines != nil {
+		s.lines.Flush()
+	}
+}
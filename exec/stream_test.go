@@ -0,0 +1,51 @@
+package exec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineWriterPartialAndCRLF(t *testing.T) {
+	lines := []string{}
+	w := newLineWriter(0, func(line string) {
+		lines = append(lines, line)
+	})
+
+	_, _ = w.Write([]byte("hel"))
+	_, _ = w.Write([]byte("lo\r\nwor"))
+	_, _ = w.Write([]byte("ld\n\nlast"))
+	assert.Equal(t, []string{"hello", "world", ""}, lines)
+
+	w.Flush()
+	assert.Equal(t, []string{"hello", "world", "", "last"}, lines)
+}
+
+func TestLineWriterLongLines(t *testing.T) {
+	lines := []string{}
+	w := newLineWriter(4, func(line string) {
+		lines = append(lines, line)
+	})
+
+	_, _ = w.Write([]byte(strings.Repeat("a", 10) + "\n"))
+	assert.Equal(t, []string{"aaaa", "aaaa", "aa"}, lines)
+	assert.LessOrEqual(t, cap(w.buf), 256)
+}
+
+func TestCmdOnStdoutLine(t *testing.T) {
+	_, ok := Which("echo")
+	if !ok {
+		t.Skip("echo not found")
+	}
+
+	lines := []string{}
+	o, err := New("echo", "hello").OnStdoutLine(func(line string) {
+		lines = append(lines, line)
+	}).Output()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, o.Code)
+	assert.Equal(t, []string{"hello"}, lines)
+	assert.Equal(t, "hello", strings.TrimSpace(o.Text()))
+}
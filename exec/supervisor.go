@@ -0,0 +1,544 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	RESTART_NEVER      = 0
+	RESTART_ON_FAILURE = 1
+	RESTART_ALWAYS     = 2
+)
+
+const (
+	SERVICE_PENDING = "pending"
+	SERVICE_RUNNING = "running"
+	SERVICE_BACKOFF = "backoff" // waiting to be restarted
+	SERVICE_EXITED  = "exited"  // exited with code 0 and not restarted
+	SERVICE_FAILED  = "failed"  // exited with an error and not restarted
+	SERVICE_STOPPED = "stopped" // stopped by the supervisor
+)
+
+const (
+	// DefaultLogLines is the number of output lines kept in memory
+	// for each supervised service.
+	DefaultLogLines = 1000
+)
+
+// RestartPolicy decides if and when a supervised service is restarted
+// after it exits.
+type RestartPolicy struct {
+	// Mode is one of RESTART_NEVER, RESTART_ON_FAILURE or
+	// RESTART_ALWAYS.
+	Mode int
+	// MaxRestarts limits the number of restarts. Zero means no limit.
+	MaxRestarts int
+	// Delay is the wait before the first restart.
+	Delay time.Duration
+	// MaxDelay caps the wait between restarts when greater than zero.
+	MaxDelay time.Duration
+	// Multiplier is applied to the delay after each restart. Values
+	// less than or equal to 1 result in a fixed backoff.
+	Multiplier float64
+}
+
+// RestartOnFailure returns a policy that restarts a service that
+// exits with an error, doubling the delay after each restart.
+func RestartOnFailure(delay, maxDelay time.Duration) *RestartPolicy {
+	return &RestartPolicy{Mode: RESTART_ON_FAILURE, Delay: delay, MaxDelay: maxDelay, Multiplier: 2}
+}
+
+// RestartAlways returns a policy that restarts a service whenever it
+// exits, doubling the delay after each restart.
+func RestartAlways(delay, maxDelay time.Duration) *RestartPolicy {
+	return &RestartPolicy{Mode: RESTART_ALWAYS, Delay: delay, MaxDelay: maxDelay, Multiplier: 2}
+}
+
+func (p *RestartPolicy) restart(code int, restarts int) bool {
+	if p == nil || p.Mode == RESTART_NEVER {
+		return false
+	}
+
+	if p.MaxRestarts > 0 && restarts >= p.MaxRestarts {
+		return false
+	}
+
+	return p.Mode == RESTART_ALWAYS || code != 0
+}
+
+func (p *RestartPolicy) backoff(restarts int) time.Duration {
+	rp := &RetryPolicy{Delay: p.Delay, MaxDelay: p.MaxDelay, Multiplier: p.Multiplier}
+	return rp.Backoff(restarts + 1)
+}
+
+// ServiceStatus is a snapshot of a supervised service.
+type ServiceStatus struct {
+	Name      string
+	State     string
+	Pid       int
+	Restarts  int
+	StartedAt time.Time
+	// ExitCode and Err describe the last exit of the service.
+	ExitCode int
+	Err      error
+}
+
+// Supervisor runs named long-running commands such as local databases
+// or mock servers, restarts them according to their restart policy and
+// stops them in reverse order on shutdown.
+//
+// Each service is started in its own process group. The output of a
+// service is kept in memory, see Logs, and when a log directory is set
+// written to <dir>/<name>.log, rotated once it reaches the maximum
+// size.
+type Supervisor struct {
+	mu          sync.Mutex
+	services    []*service
+	logDir      string
+	maxLogSize  int64
+	maxLogFiles int
+	logLines    int
+	started     bool
+}
+
+type service struct {
+	name     string
+	cmd      *Cmd
+	policy   *RestartPolicy
+	stdout   io.Writer
+	stderr   io.Writer
+	log      *rotatingFile
+	mu       sync.Mutex
+	status   ServiceStatus
+	lines    []string
+	maxLines int
+	streams  []*stream
+	stopping bool
+	stop     chan struct{}
+	done     chan struct{}
+	released sync.Once
+}
+
+func NewSupervisor() *Supervisor {
+	return &Supervisor{logLines: DefaultLogLines}
+}
+
+// WithLogDir writes the output of each service to <dir>/<name>.log.
+// A log is rotated once it reaches maxSize bytes, keeping at most
+// maxFiles rotated files named <name>.log.1, <name>.log.2 and so on.
+func (s *Supervisor) WithLogDir(dir string, maxSize int64, maxFiles int) *Supervisor {
+	s.logDir = dir
+	s.maxLogSize = maxSize
+	s.maxLogFiles = maxFiles
+	return s
+}
+
+// WithLogLines sets how many output lines are kept in memory for each
+// service. Defaults to DefaultLogLines.
+func (s *Supervisor) WithLogLines(n int) *Supervisor {
+	s.logLines = n
+	return s
+}
+
+// Add adds a service. Services are started in the order they are added
+// and stopped in reverse order. A nil policy never restarts the
+// service.
+func (s *Supervisor) Add(name string, cmd *Cmd, policy *RestartPolicy) *Supervisor {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	svc := &service{
+		name:   name,
+		cmd:    cmd,
+		policy: policy,
+		stdout: cmd.Cmd.Stdout,
+		stderr: cmd.Cmd.Stderr,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		status: ServiceStatus{Name: name, State: SERVICE_PENDING},
+	}
+
+	s.services = append(s.services, svc)
+	return s
+}
+
+// Start starts all services. When a service fails to start, the
+// services started before it are stopped and the error is returned.
+func (s *Supervisor) Start() error {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return errors.New("supervisor already started")
+	}
+
+	s.started = true
+	services := s.services
+	s.mu.Unlock()
+
+	for i, svc := range services {
+		svc.maxLines = s.logLines
+		if s.logDir != "" {
+			svc.log = &rotatingFile{
+				path:     filepath.Join(s.logDir, svc.name+".log"),
+				maxSize:  s.maxLogSize,
+				maxFiles: s.maxLogFiles,
+			}
+		}
+
+		if err := svc.launch(false); err != nil {
+			svc.exited(-1, err)
+			for _, next := range services[i:] {
+				close(next.done)
+				next.release()
+			}
+
+			for j := i - 1; j >= 0; j-- {
+				services[j].shutdown()
+			}
+
+			return fmt.Errorf("failed to start service %s: %w", svc.name, err)
+		}
+
+		go svc.run()
+	}
+
+	return nil
+}
+
+// Stop stops all services in reverse order and waits for them to
+// exit. Each service is stopped gracefully, see Cmd.Stop.
+func (s *Supervisor) Stop() {
+	s.mu.Lock()
+	services := s.services
+	started := s.started
+	s.mu.Unlock()
+	if !started {
+		return
+	}
+
+	for i := len(services) - 1; i >= 0; i-- {
+		services[i].shutdown()
+	}
+}
+
+// Wait blocks until all services have exited and will not be
+// restarted.
+func (s *Supervisor) Wait() {
+	s.mu.Lock()
+	services := s.services
+	s.mu.Unlock()
+	for _, svc := range services {
+		<-svc.done
+	}
+}
+
+// Status returns the status of all services in the order they were
+// added.
+func (s *Supervisor) Status() []ServiceStatus {
+	s.mu.Lock()
+	services := s.services
+	s.mu.Unlock()
+	set := make([]ServiceStatus, len(services))
+	for i, svc := range services {
+		set[i] = svc.getStatus()
+	}
+
+	return set
+}
+
+// StatusOf returns the status of the named service.
+func (s *Supervisor) StatusOf(name string) (ServiceStatus, bool) {
+	svc := s.find(name)
+	if svc == nil {
+		return ServiceStatus{}, false
+	}
+
+	return svc.getStatus(), true
+}
+
+// Logs returns the most recent output lines of the named service,
+// stdout and stderr combined.
+func (s *Supervisor) Logs(name string) []string {
+	svc := s.find(name)
+	if svc == nil {
+		return nil
+	}
+
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	set := make([]string, len(svc.lines))
+	copy(set, svc.lines)
+	return set
+}
+
+func (s *Supervisor) find(name string) *service {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, svc := range s.services {
+		if svc.name == name {
+			return svc
+		}
+	}
+
+	return nil
+}
+
+// launch starts the service's command with fresh output streams.
+func (svc *service) launch(restart bool) error {
+	c := svc.cmd
+	if restart {
+		c.reset()
+	}
+
+	stdout := newStream(svc.line(c.onStdoutLine), c.maxLineLength, svc.stdout)
+	stderr := newStream(svc.line(c.onStderrLine), c.maxLineLength, svc.stderr)
+	c.Cmd.Stdout = stdout.Writer()
+	c.Cmd.Stderr = stderr.Writer()
+	c.WithProcessGroup(true)
+	// cleanup, e.g. removing a script file, must not run between
+	// restarts, see release.
+	c.managed = true
+
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	if svc.stopping {
+		return errors.New("service is stopping")
+	}
+
+	if err := c.Start(); err != nil {
+		return err
+	}
+
+	svc.streams = []*stream{stdout, stderr}
+	svc.status.State = SERVICE_RUNNING
+	svc.status.Pid = c.Pid()
+	svc.status.StartedAt = time.Now().UTC()
+	return nil
+}
+
+// run waits for the service to exit and restarts it until the policy
+// or a shutdown ends it.
+func (svc *service) run() {
+	defer close(svc.done)
+	// the log is not written again once the service stopped for good.
+	defer svc.closeLog()
+	for {
+		err := svc.cmd.Wait()
+		svc.mu.Lock()
+		streams := svc.streams
+		svc.mu.Unlock()
+		for _, s := range streams {
+			s.Close()
+		}
+
+		code := exitCode(svc.cmd.Cmd.ProcessState)
+		if !svc.exited(code, err) {
+			return
+		}
+
+		for {
+			svc.mu.Lock()
+			restarts := svc.status.Restarts
+			svc.status.State = SERVICE_BACKOFF
+			svc.mu.Unlock()
+
+			t := time.NewTimer(svc.policy.backoff(restarts))
+			select {
+			case <-svc.stop:
+				t.Stop()
+				svc.setState(SERVICE_STOPPED)
+				return
+			case <-t.C:
+			}
+
+			svc.mu.Lock()
+			svc.status.Restarts++
+			svc.mu.Unlock()
+			err := svc.launch(true)
+			if err == nil {
+				break
+			}
+
+			if !svc.exited(-1, err) {
+				return
+			}
+		}
+	}
+}
+
+// exited records the exit of the service and reports whether it
+// should be restarted.
+func (svc *service) exited(code int, err error) bool {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	svc.status.ExitCode = code
+	svc.status.Err = err
+	svc.status.Pid = 0
+	if svc.stopping {
+		svc.status.State = SERVICE_STOPPED
+		return false
+	}
+
+	if svc.policy.restart(code, svc.status.Restarts) {
+		return true
+	}
+
+	svc.status.State = SERVICE_EXITED
+	if err != nil || code != 0 {
+		svc.status.State = SERVICE_FAILED
+	}
+
+	return false
+}
+
+// shutdown stops the service and waits for it to exit.
+func (svc *service) shutdown() {
+	svc.mu.Lock()
+	if !svc.stopping {
+		svc.stopping = true
+		close(svc.stop)
+	}
+	svc.mu.Unlock()
+
+	svc.cmd.Stop()
+	<-svc.done
+	svc.release()
+	svc.closeLog()
+}
+
+func (svc *service) closeLog() {
+	if svc.log != nil {
+		_ = svc.log.Close()
+	}
+}
+
+// release runs the command's cleanup once the service will not be
+// started again.
+func (svc *service) release() {
+	svc.released.Do(svc.cmd.runCleanup)
+}
+
+func (svc *service) setState(state string) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	svc.status.State = state
+}
+
+func (svc *service) getStatus() ServiceStatus {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	return svc.status
+}
+
+// line returns a line callback that keeps the line in memory and
+// writes it to the log file before calling next. Logs are rotated on
+// line boundaries.
+func (svc *service) line(next func(line string)) func(line string) {
+	return func(line string) {
+		if svc.log != nil {
+			_, _ = svc.log.Write([]byte(line + "\n"))
+		}
+
+		svc.mu.Lock()
+		if svc.maxLines > 0 {
+			if len(svc.lines) >= svc.maxLines {
+				svc.lines = append(svc.lines[:0], svc.lines[len(svc.lines)-svc.maxLines+1:]...)
+			}
+
+			svc.lines = append(svc.lines, line)
+		}
+		svc.mu.Unlock()
+
+		if next != nil {
+			next(line)
+		}
+	}
+}
+
+// rotatingFile is a log file that is renamed to path.1 once it reaches
+// maxSize, shifting older files up to maxFiles.
+type rotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	f        *os.File
+	size     int64
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	r.f = f
+	r.size = fi.Size()
+	return nil
+}
+
+func (r *rotatingFile) rotate() error {
+	_ = r.f.Close()
+	r.f = nil
+	if r.maxFiles > 0 {
+		_ = os.Remove(fmt.Sprintf("%s.%d", r.path, r.maxFiles))
+		for i := r.maxFiles - 1; i >= 1; i-- {
+			_ = os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		}
+
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(r.path); err != nil {
+		return err
+	}
+
+	return r.open()
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
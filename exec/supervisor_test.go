@@ -0,0 +1,165 @@
+package exec_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hyprxlabs/go/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSupervisorRestartOnFailure(t *testing.T) {
+	_, ok := exec.Which("sh")
+	if !ok {
+		t.Skip("sh not found")
+	}
+
+	policy := exec.RestartOnFailure(10*time.Millisecond, 20*time.Millisecond)
+	policy.MaxRestarts = 2
+	s := exec.NewSupervisor().
+		Add("flaky", exec.New("sh", "-c", "echo run; exit 3"), policy).
+		Add("once", exec.New("sh", "-c", "echo done"), exec.RestartOnFailure(10*time.Millisecond, 0))
+
+	assert.NoError(t, s.Start())
+	s.Wait()
+
+	status, ok := s.StatusOf("flaky")
+	assert.True(t, ok)
+	assert.Equal(t, exec.SERVICE_FAILED, status.State)
+	assert.Equal(t, 2, status.Restarts)
+	assert.Equal(t, 3, status.ExitCode)
+	assert.Equal(t, []string{"run", "run", "run"}, s.Logs("flaky"))
+
+	status, _ = s.StatusOf("once")
+	assert.Equal(t, exec.SERVICE_EXITED, status.State)
+	assert.Equal(t, 0, status.Restarts)
+}
+
+func TestSupervisorStop(t *testing.T) {
+	_, ok := exec.Which("sleep")
+	if !ok {
+		t.Skip("sleep not found")
+	}
+
+	s := exec.NewSupervisor().
+		Add("db", exec.New("sleep", "10"), exec.RestartAlways(10*time.Millisecond, 0)).
+		Add("api", exec.New("sleep", "10"), exec.RestartAlways(10*time.Millisecond, 0))
+
+	assert.NoError(t, s.Start())
+	for _, status := range s.Status() {
+		assert.Equal(t, exec.SERVICE_RUNNING, status.State)
+		assert.NotZero(t, status.Pid)
+	}
+
+	start := time.Now()
+	s.Stop()
+	assert.Less(t, time.Since(start), 5*time.Second)
+	for _, status := range s.Status() {
+		assert.Equal(t, exec.SERVICE_STOPPED, status.State)
+	}
+}
+
+func TestSupervisorStartFailure(t *testing.T) {
+	_, ok := exec.Which("sleep")
+	if !ok {
+		t.Skip("sleep not found")
+	}
+
+	s := exec.NewSupervisor().
+		Add("db", exec.New("sleep", "10"), nil).
+		Add("broken", exec.New("definitely-not-a-real-command-xyz"), nil).
+		Add("never", exec.New("sleep", "10"), nil)
+
+	assert.Error(t, s.Start())
+	s.Wait()
+	status := s.Status()
+	assert.Equal(t, exec.SERVICE_STOPPED, status[0].State)
+	assert.Equal(t, exec.SERVICE_FAILED, status[1].State)
+	assert.Equal(t, exec.SERVICE_PENDING, status[2].State)
+}
+
+func TestSupervisorLogRotation(t *testing.T) {
+	_, ok := exec.Which("sh")
+	if !ok {
+		t.Skip("sh not found")
+	}
+
+	dir := t.TempDir()
+	s := exec.NewSupervisor().
+		WithLogDir(dir, 64, 2).
+		WithLogLines(5).
+		Add("chatty", exec.New("sh", "-c", "for i in 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17 18 19 20; do echo line-$i; done"), nil)
+
+	assert.NoError(t, s.Start())
+	s.Wait()
+	s.Stop()
+
+	assert.Equal(t, []string{"line-16", "line-17", "line-18", "line-19", "line-20"}, s.Logs("chatty"))
+	for _, name := range []string{"chatty.log", "chatty.log.1", "chatty.log.2"} {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		if assert.NoError(t, err, name) {
+			assert.LessOrEqual(t, fi.Size(), int64(64))
+		}
+	}
+
+	_, err := os.Stat(filepath.Join(dir, "chatty.log.3"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSupervisorLogClosedOnExit(t *testing.T) {
+	_, ok := exec.Which("sh")
+	if !ok {
+		t.Skip("sh not found")
+	}
+
+	if _, err := os.Stat("/proc/self/fd"); err != nil {
+		t.Skip("/proc/self/fd not available")
+	}
+
+	dir := t.TempDir()
+	s := exec.NewSupervisor().
+		WithLogDir(dir, 0, 0).
+		Add("once", exec.New("sh", "-c", "echo done"), exec.RestartOnFailure(10*time.Millisecond, 0))
+
+	assert.NoError(t, s.Start())
+	s.Wait()
+	defer s.Stop()
+
+	log := filepath.Join(dir, "once.log")
+	assert.FileExists(t, log)
+	fds, err := os.ReadDir("/proc/self/fd")
+	assert.NoError(t, err)
+	for _, fd := range fds {
+		target, _ := os.Readlink(filepath.Join("/proc/self/fd", fd.Name()))
+		assert.NotEqual(t, log, target)
+	}
+}
+
+func TestSupervisorRestartScript(t *testing.T) {
+	_, ok := exec.Which("sh")
+	if !ok {
+		t.Skip("sh not found")
+	}
+
+	c, err := exec.Script("sh", "echo run")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	file := c.Args[len(c.Args)-1]
+	policy := exec.RestartAlways(10*time.Millisecond, 0)
+	policy.MaxRestarts = 2
+	s := exec.NewSupervisor().Add("script", c, policy)
+
+	assert.NoError(t, s.Start())
+	s.Wait()
+	status, _ := s.StatusOf("script")
+	assert.Equal(t, exec.SERVICE_EXITED, status.State)
+	assert.Equal(t, []string{"run", "run", "run"}, s.Logs("script"))
+	assert.FileExists(t, file)
+
+	s.Stop()
+	assert.NoFileExists(t, file)
+}
@@ -0,0 +1,174 @@
+package exec
+
+import (
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultGracePeriod is the time a command is given to exit after
+	// the stop signal is sent before it is killed.
+	DefaultGracePeriod = 5 * time.Second
+)
+
+// WithTimeout limits how long the command may run. When the timeout
+// elapses the stop signal is sent to the command's process group,
+// followed by a kill once the grace period has passed. The command
+// is started in a new process group when a timeout is set.
+func (c *Cmd) WithTimeout(d time.Duration) *Cmd {
+	c.timeout = d
+	return c
+}
+
+// WithGracePeriod sets how long to wait after sending the stop
+// signal before the process group is killed. The grace period also
+// applies when the context of a command created with NewContext is
+// cancelled.
+func (c *Cmd) WithGracePeriod(d time.Duration) *Cmd {
+	c.gracePeriod = d
+	return c
+}
+
+// WithStopSignal sets the signal sent when the command times out.
+// Defaults to SIGTERM on POSIX systems.
+func (c *Cmd) WithStopSignal(sig os.Signal) *Cmd {
+	c.stopSignal = sig
+	return c
+}
+
+// WithProcessGroup starts the command in a new process group so that
+// Stop and timeouts also terminate the processes it spawns. Commands
+// in their own process group should not read from an interactive
+// terminal.
+func (c *Cmd) WithProcessGroup(enabled bool) *Cmd {
+	c.processGroup = enabled
+	return c
+}
+
+// TimedOut reports whether the last run of the command was stopped
+// because its timeout elapsed.
+func (c *Cmd) TimedOut() bool {
+	return atomic.LoadInt32(&c.timedOut) == 1
+}
+
+// Stop gracefully stops the command by sending the stop signal to it,
+// or to its process group when it runs in one, and killing it if it
+// has not exited after the grace period. Calling Stop before the
+// command starts stops it as soon as it is started, calling it after
+// the command has exited has no effect on later runs. Stop does not
+// wait for the command to exit and prevents further retry attempts.
+func (c *Cmd) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.finished {
+		return
+	}
+
+	c.stopped = true
+	if c.done == nil {
+		c.stopping = true
+		return
+	}
+
+	go c.terminate(c.Cmd.Process, c.done)
+}
+
+// begin marks the start of a run so that Stop applies to it, even
+// when it is called before the command is started.
+func (c *Cmd) begin() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.finished = false
+}
+
+// finish clears the stop requests of a run once it has ended.
+func (c *Cmd) finish() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopped = false
+	c.stopping = false
+	c.finished = true
+}
+
+func (c *Cmd) isStopped() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stopped
+}
+
+// watchTimeout starts a timer for a started command that terminates
+// the command unless done is closed first.
+func (c *Cmd) watchTimeout(p *os.Process, done <-chan struct{}) {
+	if c.timeout <= 0 {
+		return
+	}
+
+	go func() {
+		t := time.NewTimer(c.timeout)
+		defer t.Stop()
+		select {
+		case <-done:
+			return
+		case <-t.C:
+		}
+
+		atomic.StoreInt32(&c.timedOut, 1)
+		c.terminate(p, done)
+	}()
+}
+
+// cancelable reports whether the command has a context that can be
+// cancelled. Such commands run in their own process group so the
+// cancellation also stops their descendants, see stdinIsTerminal.
+func (c *Cmd) cancelable() bool {
+	return c.ctx != nil && (*c.ctx).Done() != nil
+}
+
+// stdinIsTerminal reports whether the command reads from a terminal.
+// Moving such a command to a new process group would take it out of
+// the foreground, so it would miss Ctrl-C and be stopped when reading.
+func (c *Cmd) stdinIsTerminal() bool {
+	f, ok := c.Cmd.Stdin.(*os.File)
+	if !ok || f == nil {
+		return false
+	}
+
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func (c *Cmd) grace() time.Duration {
+	if c.gracePeriod <= 0 {
+		return DefaultGracePeriod
+	}
+
+	return c.gracePeriod
+}
+
+// terminate sends the stop signal and kills the process if it has
+// not exited before the grace period ends or done is closed.
+func (c *Cmd) terminate(p *os.Process, done <-chan struct{}) {
+	sig := c.stopSignal
+	if sig == nil {
+		sig = defaultStopSignal
+	}
+
+	grace := c.grace()
+	if err := signalProcess(p, sig, c.group); err != nil {
+		_ = killProcess(p, c.group)
+		return
+	}
+
+	t := time.NewTimer(grace)
+	defer t.Stop()
+	select {
+	case <-done:
+		// the leader exited, make sure no descendants are left behind.
+		if c.group {
+			_ = killProcess(p, true)
+		}
+	case <-t.C:
+		_ = killProcess(p, c.group)
+	}
+}
@@ -0,0 +1,47 @@
+package exec_test
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/hyprxlabs/go/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeoutSendsStopSignal(t *testing.T) {
+	_, ok := exec.Which("sh")
+	if !ok || runtime.GOOS == "windows" {
+		t.Skip("sh not found")
+	}
+
+	start := time.Now()
+	o, err := exec.New("sh", "-c", "sleep 10").
+		WithTimeout(100 * time.Millisecond).
+		WithGracePeriod(time.Second).
+		Output()
+
+	assert.Error(t, err)
+	assert.True(t, o.TimedOut)
+	assert.Equal(t, "SIGTERM", o.Signal)
+	assert.Equal(t, 143, o.Code)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestTimeoutEscalatesToKill(t *testing.T) {
+	_, ok := exec.Which("sh")
+	if !ok || runtime.GOOS == "windows" {
+		t.Skip("sh not found")
+	}
+
+	start := time.Now()
+	o, err := exec.New("sh", "-c", "trap '' TERM; sleep 10 & wait").
+		WithTimeout(100 * time.Millisecond).
+		WithGracePeriod(200 * time.Millisecond).
+		Output()
+
+	assert.Error(t, err)
+	assert.True(t, o.TimedOut)
+	assert.Equal(t, "SIGKILL", o.Signal)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
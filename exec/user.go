@@ -0,0 +1,119 @@
+package exec
+
+import (
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// SudoOptions configures how Sudo runs the command.
+type SudoOptions struct {
+	// User runs the command as this user instead of root (sudo -u).
+	User string
+	// NonInteractive fails instead of prompting for a password (sudo -n).
+	NonInteractive bool
+	// PreserveEnv keeps the caller's environment (sudo -E).
+	PreserveEnv bool
+	// Askpass is a helper program that prints the password. It is
+	// passed to sudo in SUDO_ASKPASS (sudo -A).
+	Askpass string
+}
+
+// AsUser runs the command as another user. The user is a name or
+// uid, optionally followed by ":group", e.g. "postgres", "1000" or
+// "1000:1000". It is resolved when the command starts and runs with
+// the user's primary and supplementary groups unless a group is
+// given. A uid without a known user uses the uid as gid. Switching
+// users requires privileges, usually root. On Windows Start returns
+// ErrNotSupported.
+func (c *Cmd) AsUser(user string) *Cmd {
+	c.runAs = user
+	return c
+}
+
+// Sudo rewrites the command to run through sudo. A nil opts uses the
+// defaults:
+//
+//	exec.New("apt-get", "install", "-y", "curl").Sudo(&exec.SudoOptions{NonInteractive: true})
+//	// sudo -n -- apt-get install -y curl
+//
+// When the process already runs as root and no user is given the
+// command is left unchanged, so scripts also work in containers
+// without sudo. Arguments added after Sudo are appended to the
+// command. Dry runs and String show the full sudo command line.
+//
+// sudo resets the environment unless PreserveEnv is set. Variables
+// set with SetEnv before Sudo are passed as sudo VAR=value arguments,
+// which the sudo policy may reject. Other variables only reach sudo
+// itself.
+func (c *Cmd) Sudo(opts *SudoOptions) *Cmd {
+	if opts == nil {
+		opts = &SudoOptions{}
+	}
+
+	if opts.User == "" && os.Geteuid() == 0 {
+		return c
+	}
+
+	// sudo sets the variables for the command, they are collected
+	// before SUDO_ASKPASS is added for sudo itself.
+	var vars []string
+	if !opts.PreserveEnv {
+		keys := make([]string, 0, len(c.envSet))
+		for k := range c.envSet {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+		for _, k := range keys {
+			vars = append(vars, c.envSet[k].key+"="+c.envSet[k].value)
+		}
+	}
+
+	args := []string{"sudo"}
+	if opts.NonInteractive {
+		args = append(args, "-n")
+	}
+
+	if opts.PreserveEnv {
+		args = append(args, "-E")
+	}
+
+	if opts.Askpass != "" {
+		args = append(args, "-A")
+		c.SetEnv("SUDO_ASKPASS", opts.Askpass)
+	}
+
+	if opts.User != "" {
+		args = append(args, "-u", opts.User)
+	}
+
+	// sudo looks the command up in its own secure path, so pass the
+	// name unless it was given as a path.
+	name := c.Cmd.Path
+	var rest []string
+	if len(c.Cmd.Args) > 0 {
+		rest = c.Cmd.Args[1:]
+		if !strings.ContainsAny(c.Cmd.Args[0], `/\`) {
+			name = c.Cmd.Args[0]
+		}
+	}
+
+	// sudo only reads VAR=value before "--", which keeps it from
+	// parsing the arguments as its own options.
+	args = append(args, vars...)
+	args = append(args, "--", name)
+	args = append(args, rest...)
+
+	var next *exec.Cmd
+	if c.ctx != nil {
+		next = exec.CommandContext(*c.ctx, "sudo")
+	} else {
+		next = exec.Command("sudo")
+	}
+
+	next.Args = args
+	c.replace(next)
+	return c
+}
@@ -0,0 +1,121 @@
+//go:build !windows
+// +build !windows
+
+package exec
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// applyUser sets the credentials for the user given to AsUser.
+func (c *Cmd) applyUser() error {
+	if c.runAs == "" {
+		return nil
+	}
+
+	cred, err := lookupCredential(c.runAs)
+	if err != nil {
+		return err
+	}
+
+	if c.Cmd.SysProcAttr == nil {
+		c.Cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	c.Cmd.SysProcAttr.Credential = cred
+	return nil
+}
+
+// lookupCredential resolves a user name, uid or "uid:gid".
+func lookupCredential(spec string) (*syscall.Credential, error) {
+	name, group := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		name, group = spec[:i], spec[i+1:]
+	}
+
+	if uid, err := strconv.ParseUint(name, 10, 32); err == nil {
+		cred := &syscall.Credential{Uid: uint32(uid), Gid: uint32(uid)}
+		if group == "" {
+			// use the primary group when the uid belongs to a known user.
+			if u, err := user.LookupId(name); err == nil {
+				return userCredential(u)
+			}
+
+			return cred, nil
+		}
+
+		gid, err := lookupGroup(group)
+		if err != nil {
+			return nil, err
+		}
+
+		cred.Gid = gid
+		return cred, nil
+	}
+
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	cred, err := userCredential(u)
+	if err != nil {
+		return nil, err
+	}
+
+	if group != "" {
+		if cred.Gid, err = lookupGroup(group); err != nil {
+			return nil, err
+		}
+	}
+
+	return cred, nil
+}
+
+func userCredential(u *user.User) (*syscall.Credential, error) {
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid uid %q for user %s", u.Uid, u.Username)
+	}
+
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gid %q for user %s", u.Gid, u.Username)
+	}
+
+	cred := &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+	ids, err := u.GroupIds()
+	if err != nil {
+		return cred, nil
+	}
+
+	for _, id := range ids {
+		if g, err := strconv.ParseUint(id, 10, 32); err == nil {
+			cred.Groups = append(cred.Groups, uint32(g))
+		}
+	}
+
+	return cred, nil
+}
+
+func lookupGroup(group string) (uint32, error) {
+	if gid, err := strconv.ParseUint(group, 10, 32); err == nil {
+		return uint32(gid), nil
+	}
+
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return 0, err
+	}
+
+	gid, err := strconv.ParseUint(g.Gid, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid gid %q for group %s", g.Gid, group)
+	}
+
+	return uint32(gid), nil
+}
@@ -0,0 +1,67 @@
+package exec_test
+
+import (
+	"errors"
+	"os"
+	osexec "os/exec"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/hyprxlabs/go/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAsUser(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() != 0 {
+		t.Skip("requires root")
+	}
+
+	_, ok := exec.Which("id")
+	if !ok {
+		t.Skip("id not found")
+	}
+
+	o, err := exec.New("id", "-u").AsUser("65534").Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "65534", strings.TrimSpace(o.Text()))
+
+	o, err = exec.New("id", "-g").AsUser("65534:123").Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "123", strings.TrimSpace(o.Text()))
+
+	_, err = exec.New("id").AsUser("definitely-not-a-user").Output()
+	var se *exec.StartError
+	assert.True(t, errors.As(err, &se))
+}
+
+func TestSudo(t *testing.T) {
+	c := exec.New("ls", "-la", "", "my dir").Sudo(&exec.SudoOptions{
+		User:           "postgres",
+		NonInteractive: true,
+		PreserveEnv:    true,
+		Askpass:        "/usr/bin/askpass",
+	})
+
+	assert.Equal(t, []string{"sudo", "-n", "-E", "-A", "-u", "postgres", "--", "ls", "-la", "", "my dir"}, c.Args)
+	assert.Contains(t, c.Environ(), "SUDO_ASKPASS=/usr/bin/askpass")
+
+	c = exec.New("./script.sh", "arg").Sudo(&exec.SudoOptions{User: "root"})
+	assert.Equal(t, []string{"sudo", "-u", "root", "--", "./script.sh", "arg"}, c.Args)
+
+	c = exec.New("whoami").Sudo(nil)
+	if os.Geteuid() == 0 {
+		assert.Equal(t, []string{"whoami"}, c.Args)
+	} else {
+		assert.Equal(t, []string{"sudo", "--", "whoami"}, c.Args)
+	}
+
+	c = exec.New("env").SetEnv("B", "2").SetEnv("A", "1 2").Sudo(&exec.SudoOptions{User: "root", NonInteractive: true, Askpass: "/bin/ask"})
+	assert.Equal(t, []string{"sudo", "-n", "-A", "-u", "root", "A=1 2", "B=2", "--", "env"}, c.Args)
+	// the path of sudo depends on the system.
+	assert.True(t, strings.HasSuffix(c.String(), `sudo -n -A -u root "A=1 2" B=2 -- env`), c.String())
+
+	c = &exec.Cmd{Cmd: &osexec.Cmd{Path: "/usr/bin/env"}}
+	c = c.Sudo(&exec.SudoOptions{User: "root"})
+	assert.Equal(t, []string{"sudo", "-u", "root", "--", "/usr/bin/env"}, c.Args)
+}
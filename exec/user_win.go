@@ -0,0 +1,12 @@
+//go:build windows
+// +build windows
+
+package exec
+
+func (c *Cmd) applyUser() error {
+	if c.runAs == "" {
+		return nil
+	}
+
+	return ErrNotSupported
+}
@@ -0,0 +1,303 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	defaultVersionPattern = regexp.MustCompile(`\d+\.\d+(?:\.\d+)?(?:-[0-9A-Za-z.]+)?`)
+)
+
+// Version is a semantic version detected for an executable.
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+	Pre   string
+}
+
+// ParseVersion parses a version such as "18", "v1.2" or "1.2.3-rc.1".
+// Build metadata after "+" is ignored.
+func ParseVersion(s string) (*Version, error) {
+	v, parts, err := parseVersion(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if parts < 0 {
+		return nil, errors.New("invalid version: " + s)
+	}
+
+	return v, nil
+}
+
+// parseVersion returns the version and the number of numeric parts
+// that were given. Wildcards such as "18.x" stop the count and return
+// -1 parts for "*".
+func parseVersion(s string) (*Version, int, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "v"), "V")
+	if i := strings.Index(s, "+"); i >= 0 {
+		s = s[:i]
+	}
+
+	v := &Version{}
+	if i := strings.Index(s, "-"); i >= 0 {
+		v.Pre = s[i+1:]
+		s = s[:i]
+	}
+
+	if s == "" {
+		return nil, 0, errors.New("invalid version: empty")
+	}
+
+	fields := strings.Split(s, ".")
+	if len(fields) > 3 {
+		return nil, 0, errors.New("invalid version: " + s)
+	}
+
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, f := range fields {
+		if f == "x" || f == "X" || f == "*" {
+			if i == 0 {
+				return v, -1, nil
+			}
+
+			return v, i, nil
+		}
+
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return nil, 0, errors.New("invalid version: " + s)
+		}
+
+		*nums[i] = n
+	}
+
+	return v, len(fields), nil
+}
+
+// Compare returns -1, 0 or 1 when v is lower than, equal to or greater
+// than o. A pre-release is lower than the release it precedes.
+func (v *Version) Compare(o *Version) int {
+	if d := compareInt(v.Major, o.Major); d != 0 {
+		return d
+	}
+
+	if d := compareInt(v.Minor, o.Minor); d != 0 {
+		return d
+	}
+
+	if d := compareInt(v.Patch, o.Patch); d != 0 {
+		return d
+	}
+
+	switch {
+	case v.Pre == o.Pre:
+		return 0
+	case v.Pre == "":
+		return 1
+	case o.Pre == "":
+		return -1
+	case v.Pre < o.Pre:
+		return -1
+	default:
+		return 1
+	}
+}
+
+func (v *Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+
+	return s
+}
+
+func compareInt(a, b int) int {
+	if a < b {
+		return -1
+	}
+
+	if a > b {
+		return 1
+	}
+
+	return 0
+}
+
+// VersionConstraint is a set of version ranges, e.g. ">=18",
+// ">=1.2, <2", "^3.1" or "~1.4 || >=2.0". Comparators separated by
+// spaces or commas must all match, ranges separated by "||" match if
+// any of them does. A version without an operator such as "18" or
+// "18.x" matches all versions with that prefix.
+type VersionConstraint struct {
+	raw    string
+	ranges [][]versionCheck
+}
+
+type versionCheck struct {
+	op      string
+	version *Version
+}
+
+// ParseConstraint parses a version constraint.
+func ParseConstraint(s string) (*VersionConstraint, error) {
+	vc := &VersionConstraint{raw: s}
+	for _, part := range strings.Split(s, "||") {
+		tokens := strings.Fields(strings.ReplaceAll(part, ",", " "))
+		checks := make([]versionCheck, 0)
+		for i := 0; i < len(tokens); i++ {
+			token := tokens[i]
+			if strings.Trim(token, "<>=!^~") == "" && i+1 < len(tokens) {
+				i++
+				token += tokens[i]
+			}
+
+			next, err := parseCheck(token)
+			if err != nil {
+				return nil, fmt.Errorf("invalid version constraint %q: %w", s, err)
+			}
+
+			checks = append(checks, next...)
+		}
+
+		vc.ranges = append(vc.ranges, checks)
+	}
+
+	return vc, nil
+}
+
+func parseCheck(token string) ([]versionCheck, error) {
+	op := ""
+	for _, prefix := range []string{">=", "<=", "!=", "==", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(token, prefix) {
+			op = prefix
+			token = token[len(prefix):]
+			break
+		}
+	}
+
+	v, parts, err := parseVersion(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if parts < 0 {
+		return nil, nil
+	}
+
+	switch op {
+	case "", "=", "==":
+		if parts == 3 {
+			return []versionCheck{{"=", v}}, nil
+		}
+
+		return []versionCheck{{">=", v}, {"<", bump(v, parts-1)}}, nil
+	case "^":
+		upper := bump(v, 0)
+		if v.Major == 0 && parts > 1 {
+			upper = bump(v, 1)
+			if v.Minor == 0 && parts > 2 {
+				upper = bump(v, 2)
+			}
+		}
+
+		return []versionCheck{{">=", v}, {"<", upper}}, nil
+	case "~":
+		if parts == 1 {
+			return []versionCheck{{">=", v}, {"<", bump(v, 0)}}, nil
+		}
+
+		return []versionCheck{{">=", v}, {"<", bump(v, 1)}}, nil
+	case ">":
+		if parts < 3 {
+			return []versionCheck{{">=", bump(v, parts-1)}}, nil
+		}
+	case "<=":
+		if parts < 3 {
+			return []versionCheck{{"<", bump(v, parts-1)}}, nil
+		}
+	}
+
+	return []versionCheck{{op, v}}, nil
+}
+
+// bump increments the version part at index and resets the lower
+// parts, e.g. bump(1.2.3, 1) is 1.3.0.
+func bump(v *Version, index int) *Version {
+	switch index {
+	case 0:
+		return &Version{Major: v.Major + 1}
+	case 1:
+		return &Version{Major: v.Major, Minor: v.Minor + 1}
+	default:
+		return &Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+	}
+}
+
+// Check reports whether the version satisfies the constraint.
+func (vc *VersionConstraint) Check(v *Version) bool {
+	for _, checks := range vc.ranges {
+		ok := true
+		for _, check := range checks {
+			if !check.match(v) {
+				ok = false
+				break
+			}
+		}
+
+		if ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (vc *VersionConstraint) String() string {
+	return vc.raw
+}
+
+func (c versionCheck) match(v *Version) bool {
+	d := v.Compare(c.version)
+	switch c.op {
+	case ">=":
+		return d >= 0
+	case ">":
+		return d > 0
+	case "<=":
+		return d <= 0
+	case "<":
+		return d < 0
+	case "!=":
+		return d != 0
+	default:
+		return d == 0
+	}
+}
+
+// extractVersion finds the version in the output of a version probe.
+// When the pattern has a capture group the first group is used.
+func extractVersion(output string, pattern *regexp.Regexp) (*Version, error) {
+	if pattern == nil {
+		pattern = defaultVersionPattern
+	}
+
+	m := pattern.FindStringSubmatch(output)
+	if m == nil {
+		return nil, errors.New("no version found in output")
+	}
+
+	s := m[0]
+	if len(m) > 1 {
+		s = m[1]
+	}
+
+	return ParseVersion(s)
+}
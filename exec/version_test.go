@@ -0,0 +1,124 @@
+package exec_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/hyprxlabs/go/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionConstraint(t *testing.T) {
+	cases := []struct {
+		constraint string
+		version    string
+		ok         bool
+	}{
+		{">=18", "18.0.0", true},
+		{">=18", "16.20.1", false},
+		{">= 1.2, <2", "1.9.9", true},
+		{">= 1.2, <2", "2.0.0", false},
+		{"^3.1", "3.9.0", true},
+		{"^3.1", "4.0.0", false},
+		{"^0.2.3", "0.2.9", true},
+		{"^0.2.3", "0.3.0", false},
+		{"~1.4", "1.4.7", true},
+		{"~1.4", "1.5.0", false},
+		{"18.x", "18.17.1", true},
+		{"18", "19.0.0", false},
+		{">2.1", "2.1.5", false},
+		{"<=2.1", "2.1.5", true},
+		{"<1 || >=3", "3.0.0", true},
+		{"<1 || >=3", "2.0.0", false},
+		{"*", "0.0.1", true},
+		{">=18", "18.0.0-rc.1", false},
+	}
+
+	for _, c := range cases {
+		vc, err := exec.ParseConstraint(c.constraint)
+		assert.NoError(t, err, c.constraint)
+		v, err := exec.ParseVersion(c.version)
+		assert.NoError(t, err, c.version)
+		assert.Equal(t, c.ok, vc.Check(v), "%s %s", c.constraint, c.version)
+	}
+
+	_, err := exec.ParseConstraint(">=abc")
+	assert.Error(t, err)
+}
+
+func TestRegistryFindVersion(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts")
+	}
+
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old", "fake-node")
+	next := filepath.Join(dir, "new", "fake-node")
+	write := func(path, version string) {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		assert.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\necho "+version+"\n"), 0o755))
+	}
+
+	write(old, "v16.20.1")
+	write(next, "v18.17.1")
+
+	r := exec.NewExecutableRegistry()
+	r.Register("fake-node", &exec.Executable{
+		Name:       "fake-node",
+		Linux:      []string{old, next},
+		Darwin:     []string{old, next},
+		Constraint: ">=18",
+	})
+
+	path, err := r.Find("fake-node", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, next, path)
+
+	v, err := r.Version("fake-node", nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "18.17.1", v.String())
+	}
+
+	r.Register("fake-node", &exec.Executable{
+		Name:       "fake-node",
+		Linux:      []string{old},
+		Darwin:     []string{old},
+		Constraint: ">=20",
+	})
+
+	_, err = r.Find("fake-node", nil)
+	var ve *exec.VersionError
+	if assert.True(t, errors.As(err, &ve)) {
+		assert.Len(t, ve.Rejected, 1)
+	}
+	assert.Contains(t, err.Error(), old+" (16.20.1)")
+
+	// a pinned path is checked against the constraint with the cache.
+	r.Register("fake-node", &exec.Executable{
+		Name:       "fake-node",
+		Path:       old,
+		Linux:      []string{next},
+		Darwin:     []string{next},
+		Constraint: ">=18",
+	})
+
+	path, err = r.Find("fake-node", &exec.WhichOptions{UseCache: true})
+	assert.NoError(t, err)
+	assert.Equal(t, next, path)
+
+	// every match on PATH is probed, not only the first.
+	t.Setenv("PATH", filepath.Dir(old)+string(os.PathListSeparator)+filepath.Dir(next))
+	r.Register("fake-node", &exec.Executable{
+		Name:       "fake-node",
+		Linux:      []string{"fake-node"},
+		Darwin:     []string{"fake-node"},
+		Constraint: ">=18",
+	})
+
+	path, err = r.Find("fake-node", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, next, path)
+}
@@ -6,18 +6,102 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"unicode"
 
 	"github.com/hyprxlabs/go/env"
 )
 
 var (
-	whichCache = make(map[string]string)
+	whichCache = &pathCache{entries: make(map[string]string)}
 )
 
+// CacheStats describes the use of a path cache.
+type CacheStats struct {
+	Entries       int
+	Hits          uint64
+	Misses        uint64
+	Invalidations uint64
+}
+
+// pathCache maps command names to resolved paths. The entries are
+// dropped when PATH changes, e.g. through env.SetPath or
+// env.PrependPath.
+type pathCache struct {
+	mu      sync.Mutex
+	entries map[string]string
+	path    string
+	stats   CacheStats
+}
+
+func (c *pathCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checkPath()
+	path, ok := c.entries[key]
+	if ok {
+		c.stats.Hits++
+	} else {
+		c.stats.Misses++
+	}
+
+	return path, ok
+}
+
+func (c *pathCache) set(key, path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checkPath()
+	c.entries[key] = path
+}
+
+func (c *pathCache) checkPath() {
+	if path := env.GetPath(); path != c.path {
+		if len(c.entries) > 0 {
+			c.clear()
+		}
+		c.path = path
+	}
+}
+
+func (c *pathCache) clear() {
+	c.entries = make(map[string]string)
+	c.stats.Invalidations++
+}
+
+// ClearWhichCache drops the paths cached by Which. The cache is
+// cleared automatically when PATH changes.
+func ClearWhichCache() {
+	whichCache.mu.Lock()
+	defer whichCache.mu.Unlock()
+	whichCache.clear()
+}
+
+// WhichCacheStats returns the statistics of the cache used by Which
+// when WhichOptions.UseCache is set.
+func WhichCacheStats() CacheStats {
+	whichCache.mu.Lock()
+	defer whichCache.mu.Unlock()
+	stats := whichCache.stats
+	stats.Entries = len(whichCache.entries)
+	return stats
+}
+
 type WhichOptions struct {
 	UseCache     bool
 	PrependPaths []string
+	// Strict matches names case-sensitively and skips files without an
+	// executable bit on POSIX systems.
+	Strict bool
+}
+
+// WhichResult is a match for a command found by WhichAll.
+type WhichResult struct {
+	Path       string
+	Dir        string // the PATH entry the match was found in
+	Symlink    bool
+	Target     string // the resolved path of a symlink
+	Executable bool
 }
 
 func Which(command string) (string, bool) {
@@ -33,11 +117,17 @@ func WhichFirst(command string, options *WhichOptions) (string, bool) {
 		options = &WhichOptions{}
 	}
 
-	base := filepath.Base(command)
-	ext := filepath.Ext(command)
-	name := base[0 : len(base)-len(ext)]
+	key := whichName(command)
+	if len(options.PrependPaths) > 0 {
+		key += string(os.PathListSeparator) + strings.Join(options.PrependPaths, string(os.PathListSeparator))
+	}
+
+	if options.Strict {
+		key += string(os.PathListSeparator) + "strict"
+	}
+
 	if options.UseCache {
-		path, ok := whichCache[name]
+		path, ok := whichCache.get(key)
 		if ok {
 			return path, true
 		}
@@ -57,13 +147,115 @@ func WhichFirst(command string, options *WhichOptions) (string, bool) {
 			}
 
 			if options.UseCache {
-				whichCache[name] = path
+				whichCache.set(key, path)
 			}
 
 			return path, true
 		}
+
+		if fi.IsDir() || (options.Strict && !isExecutable(command, fi)) {
+			return "", false
+		}
+
+		return command, true
+	}
+
+	for _, dir := range whichDirs(options) {
+		matches := findInDir(dir, command, options.Strict)
+		if len(matches) > 0 {
+			whichCache.set(key, matches[0])
+			return matches[0], true
+		}
+	}
+
+	return "", false
+}
+
+// WhichAll returns every match for the command across PATH in PATH
+// order.
+func WhichAll(command string, options *WhichOptions) []WhichResult {
+	results := make([]WhichResult, 0)
+	if command == "" {
+		return results
+	}
+
+	if options == nil {
+		options = &WhichOptions{}
+	}
+
+	if filepath.IsAbs(command) {
+		if r, ok := newWhichResult(filepath.Dir(command), command, options.Strict); ok {
+			results = append(results, r)
+		}
+
+		return results
+	}
+
+	seen := make(map[string]bool)
+	for _, dir := range whichDirs(options) {
+		if seen[dir] {
+			continue
+		}
+
+		seen[dir] = true
+		for _, path := range findInDir(dir, command, options.Strict) {
+			if r, ok := newWhichResult(dir, path, options.Strict); ok {
+				results = append(results, r)
+			}
+		}
+	}
+
+	return results
+}
+
+func newWhichResult(dir, path string, strict bool) (WhichResult, bool) {
+	lfi, err := os.Lstat(path)
+	if err != nil {
+		return WhichResult{}, false
+	}
+
+	r := WhichResult{Path: path, Dir: dir, Target: path}
+	fi := lfi
+	if lfi.Mode()&os.ModeSymlink != 0 {
+		r.Symlink = true
+		target, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return WhichResult{}, false
+		}
+
+		r.Target = target
+		fi, err = os.Stat(target)
+		if err != nil {
+			return WhichResult{}, false
+		}
+	}
+
+	if fi.IsDir() {
+		return WhichResult{}, false
+	}
+
+	r.Executable = isExecutable(r.Target, fi)
+	if strict && !r.Executable {
+		return WhichResult{}, false
+	}
+
+	return r, true
+}
+
+// whichName is the name a command is cached under. On Windows the
+// extension is dropped as any PATHEXT extension matches.
+func whichName(command string) string {
+	base := filepath.Base(command)
+	if runtime.GOOS != "windows" {
+		return base
 	}
 
+	return base[0 : len(base)-len(filepath.Ext(base))]
+}
+
+// whichDirs returns the directories to search, the prepended paths
+// first followed by PATH.
+func whichDirs(options *WhichOptions) []string {
 	pathSegments := []string{}
 	if len(options.PrependPaths) > 0 {
 		pathSegments = append(pathSegments, options.PrependPaths...)
@@ -71,90 +263,109 @@ func WhichFirst(command string, options *WhichOptions) (string, bool) {
 
 	pathSegments = append(pathSegments, env.SplitPath()...)
 
-	for i, path := range pathSegments {
+	dirs := make([]string, 0, len(pathSegments))
+	for _, path := range pathSegments {
 		value, _ := env.Expand(path)
-		if value == "" {
+		if value != "" {
+			path = value
+		}
+
+		if emptySpace(path) || notExists(path) {
 			continue
 		}
 
-		pathSegments[i] = value
+		dirs = append(dirs, path)
 	}
 
-	for _, path := range pathSegments {
-		if emptySpace(path) || notExists(path) {
-			continue
+	return dirs
+}
+
+// findInDir returns the files in dir that match the command.
+func findInDir(dir, command string, strict bool) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		// TODO: debug/trace this erro
+		return nil
+	}
+
+	matches := make([]string, 0)
+	if runtime.GOOS == "windows" {
+		pathExt := env.Get("PATHEXT")
+		if emptySpace(pathExt) {
+			pathExt = ".com;.exe;.bat;.cmd;.vbs;.vbe;.js;.jse;.wsf;.wsh"
+		} else {
+			pathExt = strings.ToLower(pathExt)
+		}
+
+		extSegments := strings.Split(pathExt, ";")
+		base := filepath.Base(command)
+		ext := filepath.Ext(base)
+		name := base[0 : len(base)-len(ext)]
+		hasExt := false
+		for _, n := range extSegments {
+			if strings.EqualFold(n, ext) {
+				hasExt = true
+				break
+			}
 		}
 
-		if runtime.GOOS == "windows" {
-			pathExt := env.Get("PATHEXT")
-			if emptySpace(pathExt) {
-				pathExt = ".com;.exe;.bat;.cmd;.vbs;.vbe;.js;.jse;.wsf;.wsh"
-			} else {
-				pathExt = strings.ToLower(pathExt)
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
 			}
 
-			extSegments := strings.Split(pathExt, ";")
+			entryName := entry.Name()
+			if hasExt {
+				if strings.EqualFold(entryName, base) {
+					matches = append(matches, filepath.Join(dir, entryName))
+				}
 
-			entries, err := os.ReadDir(path)
-			if err != nil {
-				// TODO: debug/trace this erro
 				continue
 			}
 
-			hasExt := false
+			entryExt := filepath.Ext(entryName)
+			if !strings.EqualFold(entryName[0:len(entryName)-len(entryExt)], name) {
+				continue
+			}
+
 			for _, n := range extSegments {
-				if strings.EqualFold(n, ext) {
-					hasExt = true
+				if strings.EqualFold(n, entryExt) {
+					matches = append(matches, filepath.Join(dir, entryName))
 					break
 				}
 			}
+		}
 
-			for _, entry := range entries {
-				if entry.IsDir() {
-					continue
-				}
-
-				if hasExt {
-					if strings.EqualFold(entry.Name(), command) {
-						fp := filepath.Join(path, entry.Name())
-						whichCache[name] = fp
-						return fp, true
-					}
+		return matches
+	}
 
-					continue
-				}
+	base := filepath.Base(command)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
 
-				entryName := entry.Name()
-				entryExt := filepath.Ext(entryName)
-				for _, n := range extSegments {
-					if strings.EqualFold(n, entryExt) {
-						fp := filepath.Join(path, entryName)
-						whichCache[name] = fp
-						return fp, true
-					}
-				}
-			}
-		} else {
-			entries, err := os.ReadDir(path)
-			if err != nil {
-				// TODO: debug/trace this erro
+		if strict {
+			if entry.Name() != base {
 				continue
 			}
-			for _, entry := range entries {
-				if entry.IsDir() {
-					continue
-				}
 
-				if strings.EqualFold(entry.Name(), name) {
-					fp := filepath.Join(path, entry.Name())
-					whichCache[name] = fp
-					return fp, true
-				}
+			fp := filepath.Join(dir, entry.Name())
+			fi, err := os.Stat(fp)
+			if err != nil || fi.IsDir() || !isExecutable(fp, fi) {
+				continue
 			}
+
+			matches = append(matches, fp)
+			continue
+		}
+
+		if strings.EqualFold(entry.Name(), base) {
+			matches = append(matches, filepath.Join(dir, entry.Name()))
 		}
 	}
 
-	return "", false
+	return matches
 }
 
 func notExists(path string) bool {
@@ -0,0 +1,129 @@
+package exec_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/hyprxlabs/go/env"
+	"github.com/hyprxlabs/go/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTool(t *testing.T, dir, name string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	assert.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\necho 1.0.0\n"), 0o755))
+	return path
+}
+
+func TestWhichCacheInvalidatesOnPathChange(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts")
+	}
+
+	first := t.TempDir()
+	second := t.TempDir()
+	writeTool(t, first, "exec-which-tool")
+	writeTool(t, second, "exec-which-tool")
+
+	t.Setenv("PATH", os.Getenv("PATH"))
+	assert.NoError(t, env.PrependPath(first))
+	exec.ClearWhichCache()
+
+	opts := &exec.WhichOptions{UseCache: true}
+	path, ok := exec.WhichFirst("exec-which-tool", opts)
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join(first, "exec-which-tool"), path)
+
+	before := exec.WhichCacheStats()
+	_, _ = exec.WhichFirst("exec-which-tool", opts)
+	after := exec.WhichCacheStats()
+	assert.Equal(t, before.Hits+1, after.Hits)
+	assert.GreaterOrEqual(t, after.Entries, 1)
+
+	assert.NoError(t, env.PrependPath(second))
+	path, ok = exec.WhichFirst("exec-which-tool", opts)
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join(second, "exec-which-tool"), path)
+	assert.Greater(t, exec.WhichCacheStats().Invalidations, after.Invalidations)
+}
+
+func TestRegistryConcurrentFind(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts")
+	}
+
+	dir := t.TempDir()
+	tool := writeTool(t, dir, "exec-registry-tool")
+	t.Setenv("PATH", os.Getenv("PATH"))
+	assert.NoError(t, env.PrependPath(dir))
+
+	r := exec.NewExecutableRegistry()
+	r.Register("tool", &exec.Executable{Name: "tool", Linux: []string{tool}, Darwin: []string{tool}, Constraint: ">=1"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			path, err := r.Find("tool", &exec.WhichOptions{UseCache: true})
+			assert.NoError(t, err)
+			assert.Equal(t, tool, path)
+			_, _ = exec.WhichFirst("exec-registry-tool", &exec.WhichOptions{UseCache: true})
+			_ = r.Has("tool")
+		}()
+	}
+
+	wg.Wait()
+	stats := r.Stats()
+	assert.Equal(t, 1, stats.Entries)
+	assert.Equal(t, uint64(16), stats.Hits+stats.Misses)
+
+	r.Invalidate("tool")
+	assert.Equal(t, 0, r.Stats().Entries)
+}
+
+func TestWhichAllAndStrict(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts")
+	}
+
+	first := t.TempDir()
+	second := t.TempDir()
+	third := t.TempDir()
+	tool := writeTool(t, first, "exec-all-tool")
+	assert.NoError(t, os.Symlink(tool, filepath.Join(second, "exec-all-tool")))
+	assert.NoError(t, os.WriteFile(filepath.Join(third, "exec-all-tool"), []byte("data"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(third, "EXEC-ALL-TOOL2"), []byte("data"), 0o755))
+
+	opts := &exec.WhichOptions{PrependPaths: []string{third, first, second}}
+	results := exec.WhichAll("exec-all-tool", opts)
+	if assert.Len(t, results, 3) {
+		assert.Equal(t, third, results[0].Dir)
+		assert.False(t, results[0].Executable)
+		assert.Equal(t, tool, results[1].Path)
+		assert.True(t, results[1].Executable)
+		assert.True(t, results[2].Symlink)
+		target, _ := filepath.EvalSymlinks(tool)
+		assert.Equal(t, target, results[2].Target)
+	}
+
+	path, ok := exec.WhichFirst("exec-all-tool", opts)
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join(third, "exec-all-tool"), path)
+
+	opts.Strict = true
+	assert.Len(t, exec.WhichAll("exec-all-tool", opts), 2)
+	path, ok = exec.WhichFirst("exec-all-tool", opts)
+	assert.True(t, ok)
+	assert.Equal(t, tool, path)
+
+	_, ok = exec.WhichFirst("exec-all-tool2", opts)
+	assert.False(t, ok)
+	opts.Strict = false
+	_, ok = exec.WhichFirst("exec-all-tool2", opts)
+	assert.True(t, ok)
+}